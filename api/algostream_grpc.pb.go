@@ -0,0 +1,195 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: algostream.proto
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// StreamerClient is the client API for Streamer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type StreamerClient interface {
+	SubscribeBlocks(ctx context.Context, in *SubscribeBlocksRequest, opts ...grpc.CallOption) (Streamer_SubscribeBlocksClient, error)
+	SubscribeTxns(ctx context.Context, in *SubscribeTxnsRequest, opts ...grpc.CallOption) (Streamer_SubscribeTxnsClient, error)
+}
+
+type streamerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStreamerClient(cc grpc.ClientConnInterface) StreamerClient {
+	return &streamerClient{cc}
+}
+
+func (c *streamerClient) SubscribeBlocks(ctx context.Context, in *SubscribeBlocksRequest, opts ...grpc.CallOption) (Streamer_SubscribeBlocksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Streamer_ServiceDesc.Streams[0], "/algostream.Streamer/SubscribeBlocks", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &streamerSubscribeBlocksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Streamer_SubscribeBlocksClient interface {
+	Recv() (*Block, error)
+	grpc.ClientStream
+}
+
+type streamerSubscribeBlocksClient struct {
+	grpc.ClientStream
+}
+
+func (x *streamerSubscribeBlocksClient) Recv() (*Block, error) {
+	m := new(Block)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *streamerClient) SubscribeTxns(ctx context.Context, in *SubscribeTxnsRequest, opts ...grpc.CallOption) (Streamer_SubscribeTxnsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Streamer_ServiceDesc.Streams[1], "/algostream.Streamer/SubscribeTxns", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &streamerSubscribeTxnsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Streamer_SubscribeTxnsClient interface {
+	Recv() (*Txn, error)
+	grpc.ClientStream
+}
+
+type streamerSubscribeTxnsClient struct {
+	grpc.ClientStream
+}
+
+func (x *streamerSubscribeTxnsClient) Recv() (*Txn, error) {
+	m := new(Txn)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// StreamerServer is the server API for Streamer service.
+// All implementations must embed UnimplementedStreamerServer
+// for forward compatibility
+type StreamerServer interface {
+	SubscribeBlocks(*SubscribeBlocksRequest, Streamer_SubscribeBlocksServer) error
+	SubscribeTxns(*SubscribeTxnsRequest, Streamer_SubscribeTxnsServer) error
+	mustEmbedUnimplementedStreamerServer()
+}
+
+// UnimplementedStreamerServer must be embedded to have forward compatible implementations.
+type UnimplementedStreamerServer struct {
+}
+
+func (UnimplementedStreamerServer) SubscribeBlocks(*SubscribeBlocksRequest, Streamer_SubscribeBlocksServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeBlocks not implemented")
+}
+func (UnimplementedStreamerServer) SubscribeTxns(*SubscribeTxnsRequest, Streamer_SubscribeTxnsServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeTxns not implemented")
+}
+func (UnimplementedStreamerServer) mustEmbedUnimplementedStreamerServer() {}
+
+// UnsafeStreamerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StreamerServer will
+// result in compilation errors.
+type UnsafeStreamerServer interface {
+	mustEmbedUnimplementedStreamerServer()
+}
+
+func RegisterStreamerServer(s grpc.ServiceRegistrar, srv StreamerServer) {
+	s.RegisterService(&Streamer_ServiceDesc, srv)
+}
+
+func _Streamer_SubscribeBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeBlocksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StreamerServer).SubscribeBlocks(m, &streamerSubscribeBlocksServer{stream})
+}
+
+type Streamer_SubscribeBlocksServer interface {
+	Send(*Block) error
+	grpc.ServerStream
+}
+
+type streamerSubscribeBlocksServer struct {
+	grpc.ServerStream
+}
+
+func (x *streamerSubscribeBlocksServer) Send(m *Block) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Streamer_SubscribeTxns_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeTxnsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StreamerServer).SubscribeTxns(m, &streamerSubscribeTxnsServer{stream})
+}
+
+type Streamer_SubscribeTxnsServer interface {
+	Send(*Txn) error
+	grpc.ServerStream
+}
+
+type streamerSubscribeTxnsServer struct {
+	grpc.ServerStream
+}
+
+func (x *streamerSubscribeTxnsServer) Send(m *Txn) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Streamer_ServiceDesc is the grpc.ServiceDesc for Streamer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Streamer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "algostream.Streamer",
+	HandlerType: (*StreamerServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeBlocks",
+			Handler:       _Streamer_SubscribeBlocks_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeTxns",
+			Handler:       _Streamer_SubscribeTxns_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "algostream.proto",
+}
@@ -0,0 +1,179 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//Channel is a parsed TX:<txid>;<topic>;<topic>... pub/sub channel name.
+//Topics are ACC:<address>, ASA:<id>, APP:<id>, NOTE:<base64 note prefix> and GRP:<base64 group id>.
+type Channel struct {
+	TxId   string
+	Topics []string
+}
+
+//Message is a txn received on a TX: channel
+type Message struct {
+	Channel *Channel
+	Tx      *TxWrap
+}
+
+//ParseChannel parses the channel name txns are published on
+func ParseChannel(name string) (*Channel, error) {
+	if !strings.HasPrefix(name, "TX:") {
+		return nil, fmt.Errorf("[CLIENT] %s is not a txn channel", name)
+	}
+	a := strings.Split(name[3:], ";")
+	if a[0] == "" {
+		return nil, fmt.Errorf("[CLIENT] %s has no txid", name)
+	}
+	return &Channel{TxId: a[0], Topics: a[1:]}, nil
+}
+
+//Has reports whether the txn was published with the topic, NOTE: topics match any note starting with the prefix
+func (c *Channel) Has(topic string) bool {
+	for _, t := range c.Topics {
+		if t == topic || (strings.HasPrefix(topic, "NOTE:") && strings.HasPrefix(t, topic)) {
+			return true
+		}
+	}
+	return false
+}
+
+//values returns topic values of a kind, e.g. addresses for ACC
+func (c *Channel) values(kind string) []string {
+	var v []string
+	for _, t := range c.Topics {
+		if strings.HasPrefix(t, kind+":") {
+			v = append(v, t[len(kind)+1:])
+		}
+	}
+	return v
+}
+
+func ids(v []string) []uint64 {
+	var r []uint64
+	for _, s := range v {
+		if id, err := strconv.ParseUint(s, 10, 64); err == nil {
+			r = append(r, id)
+		}
+	}
+	return r
+}
+
+//Accounts lists every address the txn touches
+func (c *Channel) Accounts() []string {
+	return c.values("ACC")
+}
+
+//Assets lists the asset IDs the txn transfers, configures, freezes or references
+func (c *Channel) Assets() []uint64 {
+	return ids(c.values("ASA"))
+}
+
+//Apps lists the called and referenced app IDs
+func (c *Channel) Apps() []uint64 {
+	return ids(c.values("APP"))
+}
+
+//Note is the base64 note prefix, up to 32 chars
+func (c *Channel) Note() string {
+	if v := c.values("NOTE"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+//Group is the base64 group ID, empty for single txns
+func (c *Channel) Group() string {
+	if v := c.values("GRP"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+//Pattern returns the PSUBSCRIBE pattern of txn channels with the topic.
+//It matches more than the topic (ASA:1 matches ASA:12 too), check the parsed channel with Has.
+func Pattern(topic string) string {
+	r := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+	return "TX:*;" + r.Replace(topic) + "*"
+}
+
+//SubscribeTxns pattern-subscribes to txn channels with any of the topics and calls fn once per txn
+//until ctx is done or fn returns an error. Pub/sub has no history, txns published while not subscribed are missed.
+//A txn published again, e.g. after a sink retry, is delivered again.
+func (c *Client) SubscribeTxns(ctx context.Context, topics []string, fn func(*Message) error) error {
+	if len(topics) == 0 {
+		return fmt.Errorf("[CLIENT] no topics to subscribe")
+	}
+	patterns := make([]string, len(topics))
+	for i, t := range topics {
+		patterns[i] = Pattern(t)
+	}
+	ps := c.rc.PSubscribe(ctx, patterns...)
+	defer ps.Close()
+	if _, err := ps.Receive(ctx); err != nil {
+		return fmt.Errorf("[CLIENT] subscribing: %s", err)
+	}
+	ch := ps.Channel()
+	//a txn matching several patterns is delivered once per pattern, back to back.
+	//A pattern repeating for the same channel starts the burst of a republished txn.
+	var (
+		prev string
+		seen = make(map[string]bool)
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case m, ok := <-ch:
+			if !ok {
+				return fmt.Errorf("[CLIENT] subscription closed")
+			}
+			if m.Channel == prev && !seen[m.Pattern] {
+				seen[m.Pattern] = true
+				continue
+			}
+			prev = m.Channel
+			seen = map[string]bool{m.Pattern: true}
+			chn, err := ParseChannel(m.Channel)
+			if err != nil {
+				continue
+			}
+			match := false
+			for _, t := range topics {
+				if chn.Has(t) {
+					match = true
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+			txw, err := DecodeTxJSON([]byte(m.Payload))
+			if err != nil {
+				return fmt.Errorf("[CLIENT] txn %s: %s", chn.TxId, err)
+			}
+			if err := fn(&Message{Channel: chn, Tx: txw}); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseChannel(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel string
+		want    *Channel
+	}{
+		{"topics", "TX:ABC;ACC:X;APP:5;ASA:1;ASA:22;GRP:Zz==;NOTE:aGVs", &Channel{TxId: "ABC", Topics: []string{"ACC:X", "APP:5", "ASA:1", "ASA:22", "GRP:Zz==", "NOTE:aGVs"}}},
+		{"no topics", "TX:ABC", &Channel{TxId: "ABC", Topics: []string{}}},
+		{"not a txn channel", "BLOCK:1", nil},
+		{"no txid", "TX:;ACC:X", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseChannel(tt.channel)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("error expected, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelFields(t *testing.T) {
+	c, err := ParseChannel("TX:ABC;ACC:X;ACC:Y;APP:5;ASA:1;ASA:22;GRP:Zz==;NOTE:aGVsbG8=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Accounts(); !reflect.DeepEqual(got, []string{"X", "Y"}) {
+		t.Errorf("Accounts() = %v", got)
+	}
+	if got := c.Assets(); !reflect.DeepEqual(got, []uint64{1, 22}) {
+		t.Errorf("Assets() = %v", got)
+	}
+	if got := c.Apps(); !reflect.DeepEqual(got, []uint64{5}) {
+		t.Errorf("Apps() = %v", got)
+	}
+	if c.Group() != "Zz==" || c.Note() != "aGVsbG8=" {
+		t.Errorf("Group() = %s, Note() = %s", c.Group(), c.Note())
+	}
+
+	tests := []struct {
+		topic string
+		want  bool
+	}{
+		{"ASA:1", true},
+		{"ASA:2", false},
+		{"ACC:Y", true},
+		{"NOTE:aGVs", true},
+		{"NOTE:aGVx", false},
+		{"GRP:Zz", false},
+	}
+	for _, tt := range tests {
+		if got := c.Has(tt.topic); got != tt.want {
+			t.Errorf("Has(%s) = %v, want %v", tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestPattern(t *testing.T) {
+	tests := []struct {
+		topic string
+		want  string
+	}{
+		{"ASA:31566704", "TX:*;ASA:31566704*"},
+		{"NOTE:a*b?", `TX:*;NOTE:a\*b\?*`},
+		{`GRP:[x]\`, `TX:*;GRP:\[x\]\\*`},
+	}
+	for _, tt := range tests {
+		if got := Pattern(tt.topic); got != tt.want {
+			t.Errorf("Pattern(%s) = %s, want %s", tt.topic, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+//Package client reads the Redis streams and pub/sub channels written by the algostreamer redis sink:
+//
+//	xblock-v2      - msgpack algod block responses, entry ID <round>-0
+//	xblock-v2-json - JSON blocks, entry ID <round>-0
+//	xtx-v2         - JSON txns, entry ID <round>-<intra>
+//	TX:<txid>;<topic>;<topic>... - pub/sub channel of every JSON txn, see ParseChannel
+package client
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	StreamBlocks     = "xblock-v2"
+	StreamBlocksJSON = "xblock-v2-json"
+	StreamTxns       = "xtx-v2"
+)
+
+const (
+	defaultCount = 100
+	defaultBlock = 5 * time.Second
+)
+
+//ReadOptions select where reading starts and how entries are acknowledged
+type ReadOptions struct {
+	//After is the entry ID reading continues after, see AfterRound.
+	//Empty reads new entries only, "0" starts with the oldest entry kept.
+	//It is ignored with Group as the group keeps its own position.
+	After string
+	//Group reads through a consumer group, it is created if missing.
+	//Entries are acknowledged once fn returns nil, entries left unacknowledged
+	//by Consumer are delivered to it again first when reading restarts.
+	Group    string
+	Consumer string
+	//Start is the entry ID a new group starts after, "$" (new entries only) by default
+	Start string
+	//Count is the number of entries fetched at once, 100 by default
+	Count int64
+	//Block is how long a single read waits for new entries, 5s by default
+	Block time.Duration
+}
+
+//Client reads algostreamer data from a Redis server
+type Client struct {
+	rc redis.UniversalClient
+}
+
+//New wraps a go-redis client connected to the server the redis sink writes to
+func New(rc redis.UniversalClient) *Client {
+	return &Client{rc: rc}
+}
+
+//AfterRound returns the entry ID to pass as After to start reading at round, in any of the streams
+func AfterRound(round uint64) string {
+	if round == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d-%d", round-1, uint64(math.MaxUint64))
+}
+
+//ReadBlocks calls fn for msgpack blocks of xblock-v2 until ctx is done or fn returns an error
+func (c *Client) ReadBlocks(ctx context.Context, opts ReadOptions, fn func(*Block) error) error {
+	return c.read(ctx, StreamBlocks, opts, func(m redis.XMessage) error {
+		b, err := DecodeBlock(m)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
+
+//ReadBlocksJSON calls fn for JSON blocks of xblock-v2-json until ctx is done or fn returns an error
+func (c *Client) ReadBlocksJSON(ctx context.Context, opts ReadOptions, fn func(*Block) error) error {
+	return c.read(ctx, StreamBlocksJSON, opts, func(m redis.XMessage) error {
+		b, err := DecodeBlockJSON(m)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
+
+//ReadTxns calls fn for txns of xtx-v2 until ctx is done or fn returns an error
+func (c *Client) ReadTxns(ctx context.Context, opts ReadOptions, fn func(*TxWrap) error) error {
+	return c.read(ctx, StreamTxns, opts, func(m redis.XMessage) error {
+		txw, err := DecodeTx(m)
+		if err != nil {
+			return err
+		}
+		return fn(txw)
+	})
+}
+
+func (c *Client) read(ctx context.Context, stream string, opts ReadOptions, fn func(redis.XMessage) error) error {
+	if opts.Count <= 0 {
+		opts.Count = defaultCount
+	}
+	if opts.Block <= 0 {
+		opts.Block = defaultBlock
+	}
+	if opts.Group != "" {
+		return c.readGroup(ctx, stream, opts, fn)
+	}
+	last := opts.After
+	if last == "" || last == "$" {
+		//"$" is resolved once, repeating it after a timed out read would skip entries added in between
+		msgs, err := c.rc.XRevRangeN(ctx, stream, "+", "-", 1).Result()
+		if err != nil {
+			return fmt.Errorf("[CLIENT] reading %s: %s", stream, err)
+		}
+		last = "0"
+		if len(msgs) > 0 {
+			last = msgs[0].ID
+		}
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		res, err := c.rc.XRead(ctx, &redis.XReadArgs{Streams: []string{stream, last}, Count: opts.Count, Block: opts.Block}).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("[CLIENT] reading %s: %s", stream, err)
+		}
+		for _, s := range res {
+			for _, m := range s.Messages {
+				if err := fn(m); err != nil {
+					return err
+				}
+				last = m.ID
+			}
+		}
+	}
+}
+
+//readGroup reads entries pending for the consumer first, then new entries of the group
+func (c *Client) readGroup(ctx context.Context, stream string, opts ReadOptions, fn func(redis.XMessage) error) error {
+	if opts.Consumer == "" {
+		return fmt.Errorf("[CLIENT] consumer name is missing")
+	}
+	start := opts.Start
+	if start == "" {
+		start = "$"
+	}
+	if err := c.rc.XGroupCreateMkStream(ctx, stream, opts.Group, start).Err(); err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("[CLIENT] creating group %s: %s", opts.Group, err)
+	}
+	id := "0"
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		args := &redis.XReadGroupArgs{Group: opts.Group, Consumer: opts.Consumer, Streams: []string{stream, id}, Count: opts.Count, Block: -1}
+		if id == ">" {
+			args.Block = opts.Block
+		}
+		res, err := c.rc.XReadGroup(ctx, args).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("[CLIENT] reading %s: %s", stream, err)
+		}
+		n := 0
+		for _, s := range res {
+			for _, m := range s.Messages {
+				n++
+				if id != ">" {
+					id = m.ID
+				}
+				//pending entries already trimmed from the stream come back without values
+				if len(m.Values) > 0 {
+					if err := fn(m); err != nil {
+						return err
+					}
+				}
+				if err := c.rc.XAck(ctx, stream, opts.Group, m.ID).Err(); err != nil {
+					return fmt.Errorf("[CLIENT] ack %s: %s", m.ID, err)
+				}
+			}
+		}
+		if id != ">" && n == 0 {
+			id = ">"
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package client
+
+import "testing"
+
+func TestAfterRound(t *testing.T) {
+	tests := []struct {
+		round uint64
+		want  string
+	}{
+		{0, "0"},
+		{1, "0-18446744073709551615"},
+		{24000000, "23999999-18446744073709551615"},
+	}
+	for _, tt := range tests {
+		if got := AfterRound(tt.round); got != tt.want {
+			t.Errorf("AfterRound(%d) = %s, want %s", tt.round, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeTxJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		json  string
+		txid  string
+		round uint64
+		intra int
+		key   string
+		typ   string
+	}{
+		{"txn", `{"txid":"ABC","txn":{"txn":{"type":"axfer","xaid":7}},"round":5,"intra":1,"xtx-v2":"5-1"}`, "ABC", 5, 1, "5-1", "axfer"},
+		{"no txn", `{"txid":"ABC"}`, "", 0, 0, "", ""},
+		{"not json", `{`, "", 0, 0, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txw, err := DecodeTxJSON([]byte(tt.json))
+			if tt.txid == "" {
+				if err == nil {
+					t.Errorf("error expected, got %+v", txw)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if txw.TxId != tt.txid || txw.Round != tt.round || txw.Intra != tt.intra || txw.Key != tt.key || string(txw.Txn.Txn.Type) != tt.typ {
+				t.Errorf("got %+v %+v", txw, txw.Txn.Txn)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+	"github.com/algorand/go-algorand-sdk/encoding/msgpack"
+	"github.com/algorand/go-algorand-sdk/types"
+	"github.com/algorand/go-codec/codec"
+	"github.com/go-redis/redis/v8"
+)
+
+var jsonHandle = &codec.JsonHandle{}
+
+//Block is a block read back from xblock-v2 or xblock-v2-json
+type Block struct {
+	//ID is the stream entry ID, <round>-0
+	ID    string
+	Block types.Block
+	//Proposer comes from the block certificate, it is only known for xblock-v2
+	Proposer types.Address
+	//Raw is the entry as stored - the msgpack algod block response or the JSON block
+	Raw []byte
+}
+
+//TxWrap is a txn as stored in xtx-v2 and published on TX: channels
+type TxWrap struct {
+	TxId string                  `json:"txid"`
+	Txn  *types.SignedTxnInBlock `json:"txn"`
+	//Round and Intra locate the txn in its block
+	Round uint64 `json:"round"`
+	Intra int    `json:"intra"`
+	//Key is the xtx-v2 entry ID, <round>-<intra>
+	Key string `json:"xtx-v2"`
+}
+
+//DecodeBlock decodes an xblock-v2 entry
+func DecodeBlock(m redis.XMessage) (*Block, error) {
+	raw, ok := m.Values["msgpack"].(string)
+	if !ok {
+		return nil, fmt.Errorf("[CLIENT] entry %s has no msgpack field", m.ID)
+	}
+	var response models.BlockResponse
+	msgpack.CodecHandle.ErrorIfNoField = false
+	if err := msgpack.Decode([]byte(raw), &response); err != nil {
+		return nil, fmt.Errorf("[CLIENT] block %s: %s", m.ID, err)
+	}
+	return &Block{ID: m.ID, Block: response.Block, Proposer: certProposer(response.Cert), Raw: []byte(raw)}, nil
+}
+
+//certProposer reads the original proposer from the block certificate, zero address if there is none
+func certProposer(cert *map[string]interface{}) (addr types.Address) {
+	if cert == nil {
+		return
+	}
+	var prop interface{}
+	switch p := (*cert)["prop"].(type) {
+	case map[string]interface{}:
+		prop = p["oprop"]
+	case map[interface{}]interface{}:
+		prop = p["oprop"]
+	}
+	switch v := prop.(type) {
+	case []byte:
+		if len(v) == len(addr) {
+			copy(addr[:], v)
+		}
+	case string:
+		if len(v) == len(addr) {
+			copy(addr[:], v)
+		}
+	}
+	return
+}
+
+//DecodeBlockJSON decodes an xblock-v2-json entry
+func DecodeBlockJSON(m redis.XMessage) (*Block, error) {
+	j, ok := m.Values["json"].(string)
+	if !ok {
+		return nil, fmt.Errorf("[CLIENT] entry %s has no json field", m.ID)
+	}
+	//bare blocks or documents with a "block" field
+	doc := []byte(j)
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return nil, fmt.Errorf("[CLIENT] block %s: %s", m.ID, err)
+	}
+	if b, ok := fields["block"]; ok {
+		doc = b
+	}
+	b := &Block{ID: m.ID, Raw: []byte(j)}
+	if err := codec.NewDecoderBytes(doc, jsonHandle).Decode(&b.Block); err != nil {
+		return nil, fmt.Errorf("[CLIENT] block %s: %s", m.ID, err)
+	}
+	return b, nil
+}
+
+//DecodeTx decodes an xtx-v2 entry
+func DecodeTx(m redis.XMessage) (*TxWrap, error) {
+	j, ok := m.Values["json"].(string)
+	if !ok {
+		return nil, fmt.Errorf("[CLIENT] entry %s has no json field", m.ID)
+	}
+	txw, err := DecodeTxJSON([]byte(j))
+	if err != nil {
+		return nil, fmt.Errorf("[CLIENT] txn %s: %s", m.ID, err)
+	}
+	return txw, nil
+}
+
+//DecodeTxJSON decodes a JSON txn as stored in xtx-v2 or published on a TX: channel
+func DecodeTxJSON(j []byte) (*TxWrap, error) {
+	txw := &TxWrap{}
+	if err := codec.NewDecoderBytes(j, jsonHandle).Decode(txw); err != nil {
+		return nil, err
+	}
+	if txw.Txn == nil {
+		return nil, fmt.Errorf("no txn field")
+	}
+	return txw, nil
+}
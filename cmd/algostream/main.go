@@ -17,19 +17,49 @@ package main
 
 import (
 	"context"
+	"errors"
+	_ "expvar"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/algonode/algostreamer/internal/algod"
 	"github.com/algonode/algostreamer/internal/config"
-	"github.com/algonode/algostreamer/internal/rdb"
-	"github.com/algonode/algostreamer/internal/simple"
+	"github.com/algonode/algostreamer/internal/rego"
+	"github.com/algonode/algostreamer/internal/sink"
+
+	//sinks register themselves in the sink registry
+	_ "github.com/algonode/algostreamer/internal/amqp"
+	_ "github.com/algonode/algostreamer/internal/aws"
+	_ "github.com/algonode/algostreamer/internal/clickhouse"
+	_ "github.com/algonode/algostreamer/internal/file"
+	_ "github.com/algonode/algostreamer/internal/jetstream"
+	_ "github.com/algonode/algostreamer/internal/kafka"
+	_ "github.com/algonode/algostreamer/internal/mqtt"
+	_ "github.com/algonode/algostreamer/internal/pg"
+	_ "github.com/algonode/algostreamer/internal/pubsub"
+	_ "github.com/algonode/algostreamer/internal/rdb"
+	_ "github.com/algonode/algostreamer/internal/rpc"
+	_ "github.com/algonode/algostreamer/internal/search"
+	_ "github.com/algonode/algostreamer/internal/simple"
+	_ "github.com/algonode/algostreamer/internal/sqlite"
+	_ "github.com/algonode/algostreamer/internal/sse"
+	_ "github.com/algonode/algostreamer/internal/webhook"
+	_ "github.com/algonode/algostreamer/internal/ws"
 )
 
 func main() {
 
+	//offline subcommands
+	if len(os.Args) > 1 && os.Args[1] == "policy" {
+		os.Exit(policyCmd(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "query" {
+		os.Exit(queryCmd(os.Args[2:]))
+	}
+
 	//load config
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -51,15 +81,45 @@ func main() {
 		}()
 	}
 
-	if !cfg.Stdout {
-		if lastBlock, err := rdb.RedisGetLastBlock(ctx, cfg.Sinks.Redis); err == nil {
-			if int64(lastBlock) > cfg.Algod.FRound {
-				cfg.Algod.FRound = int64(lastBlock)
-				fmt.Fprintf(os.Stderr, "[INFO][_MAIN] Reasuming from last redis commited block %d\n", lastBlock)
+	//expvar registers /debug/vars on the default mux
+	if cfg.Debug != "" {
+		go func() {
+			if err := http.ListenAndServe(cfg.Debug, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "[!ERR][_MAIN] debug server: %s\n", err)
 			}
+		}()
+	}
+
+	//compile OPA rules, without them everything goes everywhere
+	if cfg.Rego != nil {
+		if err := rego.CompileCfg(cfg.Rego); err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][_MAIN] error compiling OPA rules: %s\n", err)
+			return
+		}
+		//recompile changed rule files or all of them on SIGHUP
+		hupCh := make(chan os.Signal, 1)
+		signal.Notify(hupCh, syscall.SIGHUP)
+		if err := rego.Run(ctx, cfg.Rego, hupCh); err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][_MAIN] error starting OPA: %s\n", err)
+			return
 		}
 	}
 
+	sinks, err := sink.NewAll(ctx, cfg.Sinks)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][_MAIN] error setting up sinks: %s\n", err)
+		return
+	}
+
+	if lastBlock, err := sink.LastCommittedRound(ctx, sinks); err == nil {
+		if int64(lastBlock) > cfg.Algod.FRound {
+			cfg.Algod.FRound = int64(lastBlock)
+			fmt.Fprintf(os.Stderr, "[INFO][_MAIN] Reasuming from last commited block %d\n", lastBlock)
+		}
+	} else if !errors.Is(err, sink.ErrNoCheckpoint) {
+		fmt.Fprintf(os.Stderr, "[WARN][_MAIN] no resume checkpoint: %s\n", err)
+	}
+
 	//spawn a block stream fetcher that never fails
 	blocks, status, err := algod.AlgoStreamer(ctx, cfg.Algod)
 	if err != nil {
@@ -67,22 +127,12 @@ func main() {
 		return
 	}
 
-	if cfg.Stdout {
-		err = simple.SimplePusher(ctx, blocks, status)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "[!ERR][_MAIN] error setting up simple mode: %s\n", err)
-			return
-		}
-	} else {
-		//spawn a redis pusher
-		err = rdb.RedisPusher(ctx, cfg.Sinks.Redis, blocks, status)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "[!ERR][_MAIN] error setting up redis: %s\n", err)
-			return
-		}
-	}
+	//route blocks, txns and status updates to sinks
+	done := sink.FanOut(ctx, sinks, cfg.Rego, blocks, status)
 
 	//Wait for the end of the Algoverse
 	<-ctx.Done()
 
+	//Let the sinks flush
+	done.Wait()
 }
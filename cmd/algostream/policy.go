@@ -0,0 +1,192 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/config"
+	"github.com/algonode/algostreamer/internal/rego"
+	"github.com/klauspost/compress/zstd"
+)
+
+const policyUsage = `usage: algostream policy test [-f config.jsonc] [-golden dir] [-update] block-file...
+
+Runs block and tx rules from the "opa" config section against recorded blocks
+and compares decisions with <block-file>.golden files.
+Files ending with .json or .ndjson hold JSON blocks, all others msgpack blocks as returned by algod.
+Files ending with .gz or .zst are decompressed first, so file sink archives can be replayed.
+`
+
+//policyCmd handles the "policy" subcommand, returns the process exit code
+func policyCmd(args []string) int {
+	if len(args) < 1 || args[0] != "test" {
+		fmt.Fprint(os.Stderr, policyUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("policy test", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, policyUsage) }
+	cfgFile := fs.String("f", "config.jsonc", "config file")
+	goldenDir := fs.String("golden", "", "directory with golden files [default = next to block files]")
+	update := fs.Bool("update", false, "write current decisions to golden files")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	opa, err := config.LoadRegoConfig(*cfgFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][POLICY] loading config: %s\n", err)
+		return 1
+	}
+	if err := rego.CompileCfg(opa); err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][POLICY] compiling rules: %s\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	failed := 0
+	for _, file := range fs.Args() {
+		out, err := policyDecisions(ctx, opa.Compilers(), file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][POLICY] %s: %s\n", file, err)
+			failed++
+			continue
+		}
+		os.Stdout.Write(out)
+
+		golden := file + ".golden"
+		if *goldenDir != "" {
+			golden = filepath.Join(*goldenDir, filepath.Base(golden))
+		}
+		if *update {
+			if err := os.WriteFile(golden, out, 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "[!ERR][POLICY] %s\n", err)
+				failed++
+			}
+			continue
+		}
+		expected, err := os.ReadFile(golden)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[FAIL][POLICY] %s: no golden file, run with -update to create it\n", file)
+			failed++
+			continue
+		}
+		if diff := firstDiff(expected, out); diff != "" {
+			fmt.Fprintf(os.Stderr, "[FAIL][POLICY] %s: %s\n", file, diff)
+			failed++
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "[ OK ][POLICY] %s\n", file)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+//policyDecisions runs rules the same way the live pipeline does and renders one line per event
+func policyDecisions(ctx context.Context, rules *rego.RegoRulesCompilers, file string) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	name := file
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r, name = zr, strings.TrimSuffix(name, ".gz")
+	case strings.HasSuffix(name, ".zst"):
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r, name = zr, strings.TrimSuffix(name, ".zst")
+	}
+
+	var blocks []*algod.BlockWrap
+	if strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".ndjson") {
+		blocks, err = algod.ReadJSONBlocks(r, file)
+	} else {
+		blocks, err = algod.ReadMsgpBlocks(r, file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	for _, b := range blocks {
+		routes, err := rules.EvalBlock(ctx, b)
+		fmt.Fprintf(&out, "block %d: %s\n", uint64(b.Block.Round), renderRoutes(routes, err))
+		txns := algod.WrapTxns(b)
+		txRoutes, txErrs := rules.EvalTxns(ctx, txns)
+		for i, txw := range txns {
+			fmt.Fprintf(&out, "tx %s %s: %s\n", txw.Key, txw.TxId, renderRoutes(txRoutes[i], txErrs[i]))
+		}
+	}
+	return out.Bytes(), nil
+}
+
+func renderRoutes(routes []rego.Route, err error) string {
+	if err != nil {
+		return "error " + err.Error()
+	}
+	j, err := json.Marshal(routes)
+	if err != nil {
+		return "error " + err.Error()
+	}
+	return string(j)
+}
+
+//firstDiff describes the first line that differs or returns "" if there is none
+func firstDiff(expected, got []byte) string {
+	el := strings.Split(string(expected), "\n")
+	gl := strings.Split(string(got), "\n")
+	for i := 0; i < len(el) || i < len(gl); i++ {
+		var e, g string
+		if i < len(el) {
+			e = el[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if e != g {
+			return fmt.Sprintf("line %d\n  expected: %s\n  got:      %s", i+1, e, g)
+		}
+	}
+	return ""
+}
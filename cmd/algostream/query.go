@@ -0,0 +1,95 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/algonode/algostreamer/internal/sqlite"
+)
+
+const queryUsage = `usage: algostream query [-db algostream.db] "SELECT ..."
+
+Runs a read-only SQL query against a sqlite sink database and prints one JSON object per row.
+Safe to use while the streamer writes to the same file.
+`
+
+//queryCmd handles the "query" subcommand, returns the process exit code
+func queryCmd(args []string) int {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, queryUsage) }
+	dbFile := fs.String("db", "algostream.db", "sqlite sink database")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	db, err := sqlite.OpenReadOnly(*dbFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][QUERY] %s: %s\n", *dbFile, err)
+		return 1
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(context.Background(), fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][QUERY] %s\n", err)
+		return 1
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][QUERY] %s\n", err)
+		return 1
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	enc := json.NewEncoder(w)
+	vals := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][QUERY] %s\n", err)
+			return 1
+		}
+		row := make(map[string]interface{}, len(cols))
+		for i, c := range cols {
+			//blobs are base64 encoded by json, text columns come back as strings
+			row[c] = vals[i]
+		}
+		if err := enc.Encode(row); err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][QUERY] %s\n", err)
+			return 1
+		}
+	}
+	if err := rows.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][QUERY] %s\n", err)
+		return 1
+	}
+	return 0
+}
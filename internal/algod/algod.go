@@ -26,7 +26,6 @@ import (
 	"github.com/algonode/algostreamer/internal/utils"
 	"github.com/algorand/go-algorand-sdk/client/v2/algod"
 	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
-	"github.com/algorand/go-algorand-sdk/encoding/msgpack"
 
 	"github.com/algorand/go-algorand-sdk/types"
 )
@@ -45,17 +44,19 @@ type AlgoConfig struct {
 }
 
 type Status struct {
-	LastRound uint64
-	LagMs     int64
-	NodeId    string
-	LastCP    string
+	LastRound uint64 `json:"round"`
+	LagMs     int64  `json:"lag"`
+	NodeId    string `json:"node"`
+	LastCP    string `json:"lcp"`
 }
 
 type BlockWrap struct {
-	Block    *types.Block `json: "block"`
+	Block    *types.Block `json:"block"`
 	BlockRaw []byte       `json:"-"`
 	Src      string       `json:"src"`
 	Ts       time.Time    `json:"ts"`
+	//Proposer comes from the certificate of msgpack blocks
+	Proposer types.Address `json:"-"`
 }
 
 //globalMaxBlock holds the highest read block across all connected nodes
@@ -157,21 +158,14 @@ func algodStreamNode(ctx context.Context, acfg *AlgoConfig, idx int, bchan chan
 					if err != nil {
 						return fmt.Errorf("[!ERR][ALGOD][%s] %s", cfg.Id, err.Error())
 					}
-					var response models.BlockResponse
-					msgpack.CodecHandle.ErrorIfNoField = false
-					if err = msgpack.Decode(rawBlock, &response); err != nil {
+					bw, err := DecodeBlockRaw(rawBlock, cfg.Id)
+					if err != nil {
 						return fmt.Errorf("[!ERR][ALGOD][%s] %s", cfg.Id, err.Error())
 					}
-					block := response.Block
 
-					//fmt.Fprintf(os.Stderr, "got block %d, queue %d\n", block.Round, len(bchan))
+					//fmt.Fprintf(os.Stderr, "got block %d, queue %d\n", bw.Block.Round, len(bchan))
 					select {
-					case bchan <- &BlockWrap{
-						Block:    &block,
-						BlockRaw: rawBlock,
-						Ts:       time.Now(),
-						Src:      cfg.Id,
-					}:
+					case bchan <- bw:
 					case <-ctx.Done():
 					}
 					return ctx.Err()
@@ -0,0 +1,136 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package algod
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+	"github.com/algorand/go-algorand-sdk/encoding/msgpack"
+	"github.com/algorand/go-algorand-sdk/types"
+	"github.com/algorand/go-codec/codec"
+)
+
+var jsonHandle = &codec.JsonHandle{}
+
+//DecodeBlockRaw decodes a msgpack block as returned by algod and stored in BlockWrap.BlockRaw
+func DecodeBlockRaw(raw []byte, src string) (*BlockWrap, error) {
+	var response models.BlockResponse
+	msgpack.CodecHandle.ErrorIfNoField = false
+	if err := msgpack.Decode(raw, &response); err != nil {
+		return nil, err
+	}
+	return &BlockWrap{Block: &response.Block, BlockRaw: raw, Src: src, Ts: time.Now(), Proposer: certProposer(response.Cert)}, nil
+}
+
+//certProposer reads the original proposer from the block certificate, zero address if there is none
+func certProposer(cert *map[string]interface{}) (addr types.Address) {
+	if cert == nil {
+		return
+	}
+	var prop interface{}
+	switch p := (*cert)["prop"].(type) {
+	case map[string]interface{}:
+		prop = p["oprop"]
+	case map[interface{}]interface{}:
+		prop = p["oprop"]
+	}
+	switch v := prop.(type) {
+	case []byte:
+		if len(v) == len(addr) {
+			copy(addr[:], v)
+		}
+	case string:
+		if len(v) == len(addr) {
+			copy(addr[:], v)
+		}
+	}
+	return
+}
+
+//ReadMsgpBlocks reads a stream of concatenated msgpack algod block responses
+func ReadMsgpBlocks(r io.Reader, src string) ([]*BlockWrap, error) {
+	var blocks []*BlockWrap
+	err := ScanMsgpBlocks(r, src, func(bw *BlockWrap) error {
+		blocks = append(blocks, bw)
+		return nil
+	})
+	return blocks, err
+}
+
+//ScanMsgpBlocks calls fn for every block of a msgpack stream, an error from fn stops the scan
+func ScanMsgpBlocks(r io.Reader, src string, fn func(*BlockWrap) error) error {
+	msgpack.CodecHandle.ErrorIfNoField = false
+	dec := codec.NewDecoder(r, msgpack.CodecHandle)
+	for n := 0; ; n++ {
+		var raw codec.Raw
+		if err := dec.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("block #%d: %s", n, err)
+		}
+		bw, err := DecodeBlockRaw(raw, src)
+		if err != nil {
+			return fmt.Errorf("block #%d: %s", n, err)
+		}
+		if err := fn(bw); err != nil {
+			return err
+		}
+	}
+}
+
+//ReadJSONBlocks reads a stream of JSON blocks - either bare blocks (redis xblock-v2-json)
+//or documents with a "block" field (default payload of the other sinks, algod JSON response)
+func ReadJSONBlocks(r io.Reader, src string) ([]*BlockWrap, error) {
+	var blocks []*BlockWrap
+	err := ScanJSONBlocks(r, src, func(bw *BlockWrap) error {
+		blocks = append(blocks, bw)
+		return nil
+	})
+	return blocks, err
+}
+
+//ScanJSONBlocks calls fn for every block of a JSON stream, an error from fn stops the scan
+func ScanJSONBlocks(r io.Reader, src string, fn func(*BlockWrap) error) error {
+	dec := json.NewDecoder(r)
+	for n := 0; ; n++ {
+		var doc json.RawMessage
+		if err := dec.Decode(&doc); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("block #%d: %s", n, err)
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(doc, &fields); err != nil {
+			return fmt.Errorf("block #%d: %s", n, err)
+		}
+		if b, ok := fields["block"]; ok {
+			doc = b
+		}
+		var block types.Block
+		if err := codec.NewDecoderBytes(doc, jsonHandle).Decode(&block); err != nil {
+			return fmt.Errorf("block #%d: %s", n, err)
+		}
+		if err := fn(&BlockWrap{Block: &block, Src: src, Ts: time.Now()}); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package algod
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+type TxWrap struct {
+	TxId  string                  `json:"txid"`
+	Txn   *types.SignedTxnInBlock `json:"txn"`
+	Round uint64                  `json:"round"`
+	Intra int                     `json:"intra"`
+	Key   string                  `json:"xtx-v2"`
+}
+
+//WrapTxns decodes txids of all block transactions.
+//DecodeTxnId fills in genesis fields so it works on a copy and leaves the block intact.
+func WrapTxns(b *BlockWrap) []*TxWrap {
+	txns := make([]*TxWrap, 0, len(b.Block.Payset))
+	for i := range b.Block.Payset {
+		txn := b.Block.Payset[i]
+		//I just love how easy is to get txId nowadays ;)
+		txId, err := DecodeTxnId(b.Block.BlockHeader, &txn)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][ALGOD] %s\n", err)
+			continue
+		}
+		txns = append(txns, &TxWrap{
+			TxId:  txId,
+			Txn:   &txn,
+			Round: uint64(b.Block.Round),
+			Intra: i,
+			Key:   fmt.Sprintf("%d-%d", uint64(b.Block.Round), i),
+		})
+	}
+	return txns
+}
+
+//Topics returns the ACC:, ASA:, APP:, NOTE: and GRP: keys a txn can be subscribed by
+func (txw *TxWrap) Topics() []string {
+	t := make(map[string]struct{})
+
+	addACC := func(a types.Address) {
+		if a.IsZero() {
+			return
+		}
+		t["ACC:"+a.String()] = struct{}{}
+	}
+
+	addASA := func(a types.AssetIndex) {
+		if a == 0 {
+			return
+		}
+		t[fmt.Sprintf("ASA:%d", uint64(a))] = struct{}{}
+	}
+
+	addAPP := func(a types.AppIndex) {
+		if a == 0 {
+			return
+		}
+		t[fmt.Sprintf("APP:%d", uint64(a))] = struct{}{}
+	}
+
+	tx := &txw.Txn.Txn
+	addACC(tx.Sender)
+	addACC(txw.Txn.AuthAddr)
+	switch tx.Type {
+	case types.PaymentTx:
+		addACC(tx.Receiver)
+		addACC(tx.CloseRemainderTo)
+	case types.AssetTransferTx:
+		addACC(tx.AssetSender)
+		addACC(tx.AssetReceiver)
+		addACC(tx.CloseRemainderTo)
+		addASA(tx.XferAsset)
+	case types.AssetConfigTx:
+		addACC(tx.AssetConfigTxnFields.AssetParams.Manager)
+		addACC(tx.AssetConfigTxnFields.AssetParams.Reserve)
+		addACC(tx.AssetConfigTxnFields.AssetParams.Clawback)
+		addACC(tx.AssetConfigTxnFields.AssetParams.Freeze)
+		addASA(tx.ConfigAsset)
+	case types.AssetFreezeTx:
+		addACC(tx.AssetFreezeTxnFields.FreezeAccount)
+		addASA(tx.FreezeAsset)
+	case types.ApplicationCallTx:
+		addAPP(tx.ApplicationID)
+		for i := range tx.ForeignApps {
+			addAPP(tx.ForeignApps[i])
+		}
+		for i := range tx.ForeignAssets {
+			addASA(tx.ForeignAssets[i])
+		}
+		for i := range tx.Accounts {
+			addACC(tx.Accounts[i])
+		}
+	}
+	//Allow subscriptions based on note prefix (up to 32 chars in base64)
+	t["NOTE:"+txw.NotePrefix(32)] = struct{}{}
+	if tx.Group != (types.Digest{}) {
+		t["GRP:"+base64.StdEncoding.EncodeToString(tx.Group[:])] = struct{}{}
+	}
+
+	topics := make([]string, 0, len(t))
+	for k := range t {
+		if len(k) > 0 {
+			topics = append(topics, k)
+		}
+	}
+	sort.Strings(topics)
+
+	return topics
+}
+
+//NotePrefix returns up to l chars of base64 encoded note
+func (txw *TxWrap) NotePrefix(l int) string {
+	nb64 := base64.StdEncoding.EncodeToString(txw.Txn.Txn.Note)
+	if l > len(nb64) {
+		return nb64
+	}
+	return nb64[:l]
+}
+
+//Channel returns the pub/sub channel name of a txn - TX:<txid>;<topic>;<topic>...
+func (txw *TxWrap) Channel() string {
+	return fmt.Sprintf("TX:%s;%s", txw.TxId, strings.Join(txw.Topics(), ";"))
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package amqp
+
+import (
+	"testing"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+func TestRoutingKey(t *testing.T) {
+	alice, bob := types.Address{1}, types.Address{2}
+	pay := types.Transaction{Type: types.PaymentTx}
+	pay.Sender, pay.Receiver = alice, bob
+	axfer := types.Transaction{Type: types.AssetTransferTx}
+	axfer.Sender, axfer.AssetReceiver, axfer.XferAsset = alice, bob, 31566704
+	optin := axfer
+	optin.AssetReceiver = types.Address{}
+	appl := types.Transaction{Type: types.ApplicationCallTx}
+	appl.Sender, appl.ApplicationID = alice, 10
+	keyreg := types.Transaction{Type: types.KeyRegistrationTx}
+	keyreg.Sender = alice
+
+	tests := []struct {
+		name string
+		tx   types.Transaction
+		want string
+	}{
+		{"pay", pay, "tx.pay." + alice.String() + "." + bob.String() + ".-.-"},
+		{"axfer", axfer, "tx.axfer." + alice.String() + "." + bob.String() + ".31566704.-"},
+		{"no receiver", optin, "tx.axfer." + alice.String() + ".-.31566704.-"},
+		{"appl", appl, "tx.appl." + alice.String() + ".-.-.10"},
+		{"keyreg", keyreg, "tx.keyreg." + alice.String() + ".-.-.-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txw := &algod.TxWrap{Txn: &types.SignedTxnInBlock{SignedTxnWithAD: types.SignedTxnWithAD{SignedTxn: types.SignedTxn{Txn: tt.tx}}}}
+			if got := routingKey(txw); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyWord(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"node1", "node1"},
+		{"node.example.com", "node_example_com"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := keyWord(tt.in); got != tt.want {
+			t.Errorf("keyWord(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
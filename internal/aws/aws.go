@@ -0,0 +1,217 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package aws
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/algonode/algostreamer/internal/sink"
+	sdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+const (
+	//maxBatch and maxBatchSize are the SQS/SNS batch API limits
+	maxBatch     = 10
+	maxBatchSize = 256 * 1024
+)
+
+//AwsConfig is common to all AWS sinks.
+//Credentials and region fall back to the usual AWS environment and profile settings.
+type AwsConfig struct {
+	Region string `json:"region"`
+	//Endpoint overrides the service URL, e.g. http://localhost:4566 for LocalStack
+	Endpoint  string `json:"endpoint"`
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+	Profile   string `json:"profile"`
+}
+
+//MsgConfig is common to SQS and SNS sinks
+type MsgConfig struct {
+	//Blocks sends block messages as well, txns are always sent
+	Blocks bool `json:"blocks"`
+	//Status sends node status updates
+	Status bool `json:"status"`
+	//Oversize handles messages over the 256KB limit - "fail" fails the block,
+	//"drop" skips them with a warning and "s3" stores the body in the offload bucket
+	//and sends an extended client S3 pointer instead.
+	//Defaults to s3 with an offload bucket and to fail without one, fail cannot be used with blocks.
+	Oversize string         `json:"oversize"`
+	Offload  *OffloadConfig `json:"offload"`
+}
+
+func (c *AwsConfig) load(ctx context.Context) (sdk.Config, error) {
+	opts := []func(*config.LoadOptions) error{}
+	if c.Region != "" {
+		opts = append(opts, config.WithRegion(c.Region))
+	}
+	if c.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(c.Profile))
+	}
+	if c.AccessKey != "" {
+		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(c.AccessKey, c.SecretKey, "")))
+	}
+	return config.LoadDefaultConfig(ctx, opts...)
+}
+
+type attr struct {
+	dataType string
+	value    string
+}
+
+//message is a queue or topic message before conversion to service types
+type message struct {
+	//dest is a rule supplied queue or topic, empty means the configured one
+	dest  string
+	body  string
+	group string
+	dedup string
+	attrs map[string]attr
+}
+
+//blockMessages converts the block and its txns, txns first and the block last
+func blockMessages(b *sink.Block, withBlocks bool) []message {
+	round := uint64(b.Block.Round)
+	msgs := make([]message, 0, len(b.Txns)+len(b.Msgs))
+	for _, tx := range b.Txns {
+		body, err := tx.Encode(tx.TxWrap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][AWS] %s\n", err)
+			continue
+		}
+		txn := &tx.Txn.Txn
+		refs := sink.Refs(tx.TxWrap)
+		attrs := map[string]attr{
+			"type":   {"String", string(txn.Type)},
+			"sender": {"String", txn.Sender.String()},
+			"round":  {"Number", strconv.FormatUint(round, 10)},
+			"txid":   {"String", tx.TxId},
+		}
+		if !refs.Receiver.IsZero() {
+			attrs["receiver"] = attr{"String", refs.Receiver.String()}
+		}
+		if refs.Asset != 0 {
+			attrs["asset"] = attr{"Number", strconv.FormatUint(refs.Asset, 10)}
+		}
+		if refs.App != 0 {
+			attrs["app"] = attr{"Number", strconv.FormatUint(refs.App, 10)}
+		}
+		dedup := tx.Key
+		if tx.Topic != "" {
+			dedup += ":" + tx.Topic
+		}
+		msgs = append(msgs, message{
+			dest:  tx.Topic,
+			body:  string(body),
+			group: txn.Sender.String(),
+			dedup: dedup,
+			attrs: attrs,
+		})
+	}
+	for i := range b.Msgs {
+		if !withBlocks && b.Msgs[i].Topic == "" {
+			continue
+		}
+		body, err := b.Msgs[i].Encode(b.Payload())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][AWS] %s\n", err)
+			continue
+		}
+		msgs = append(msgs, message{
+			dest:  b.Msgs[i].Topic,
+			body:  string(body),
+			group: "block",
+			dedup: fmt.Sprintf("block-%d:%s", round, b.Msgs[i].Topic),
+			attrs: map[string]attr{"type": {"String", "block"}, "round": {"Number", strconv.FormatUint(round, 10)}},
+		})
+	}
+	return msgs
+}
+
+func statusMessages(status *sink.Status) []message {
+	msgs := make([]message, 0, len(status.Msgs))
+	for i := range status.Msgs {
+		body, err := status.Msgs[i].Encode(status.Status)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][AWS] %s\n", err)
+			continue
+		}
+		msgs = append(msgs, message{
+			dest:  status.Msgs[i].Topic,
+			body:  string(body),
+			group: "status",
+			dedup: fmt.Sprintf("status-%s-%d", status.NodeId, status.LastRound),
+			attrs: map[string]attr{"type": {"String", "status"}, "round": {"Number", strconv.FormatUint(status.LastRound, 10)}},
+		})
+	}
+	return msgs
+}
+
+//batches splits messages for one destination into API sized batches keeping their order,
+//messages have to fit the size limit already
+func batches(msgs []message) [][]message {
+	var (
+		out  [][]message
+		cur  []message
+		size int
+	)
+	for _, m := range msgs {
+		l := msgSize(&m)
+		if len(cur) == maxBatch || size+l > maxBatchSize {
+			out = append(out, cur)
+			cur, size = nil, 0
+		}
+		cur = append(cur, m)
+		size += l
+	}
+	if len(cur) > 0 {
+		out = append(out, cur)
+	}
+	return out
+}
+
+//byDest groups messages by destination, keeping order within each group
+func byDest(msgs []message, def string) ([]string, map[string][]message) {
+	dests := []string{}
+	groups := make(map[string][]message)
+	for _, m := range msgs {
+		d := m.dest
+		if d == "" {
+			d = def
+		}
+		if _, ok := groups[d]; !ok {
+			dests = append(dests, d)
+		}
+		groups[d] = append(groups[d], m)
+	}
+	return dests, groups
+}
+
+//dedupID keeps FIFO deduplication IDs within the 128 character limit
+func dedupID(id string) string {
+	if len(id) <= 128 {
+		return id
+	}
+	h := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,179 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package aws
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	sdk "github.com/aws/aws-sdk-go-v2/aws"
+)
+
+//sized makes n messages with bodies of l bytes, dedup IDs keep their order
+func sized(n int, l int) []message {
+	msgs := make([]message, n)
+	for i := range msgs {
+		msgs[i] = message{body: strings.Repeat("x", l), dedup: strconv.Itoa(i)}
+	}
+	return msgs
+}
+
+func TestBatches(t *testing.T) {
+	tests := []struct {
+		name string
+		msgs []message
+		want []int
+	}{
+		{"empty", nil, nil},
+		{"one", sized(1, 10), []int{1}},
+		{"count limit", sized(25, 10), []int{10, 10, 5}},
+		{"size limit", sized(5, 100*1024), []int{2, 2, 1}},
+		{"exact size", sized(4, 64*1024), []int{4}},
+		{"largest message alone", sized(2, maxBatchSize), []int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			next := 0
+			for _, b := range batches(tt.msgs) {
+				got = append(got, len(b))
+				size := 0
+				for _, m := range b {
+					if m.dedup != strconv.Itoa(next) {
+						t.Fatalf("message %s out of order, want %d", m.dedup, next)
+					}
+					next++
+					size += msgSize(&m)
+				}
+				if size > maxBatchSize {
+					t.Errorf("batch of %d bytes", size)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("batch sizes %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMsgSize(t *testing.T) {
+	m := message{body: "12345", attrs: map[string]attr{"round": {"Number", "10"}}}
+	if got := msgSize(&m); got != 5+5+6+2 {
+		t.Errorf("msgSize = %d", got)
+	}
+}
+
+func TestByDest(t *testing.T) {
+	msgs := []message{{dest: "", dedup: "1"}, {dest: "b", dedup: "2"}, {dest: "q", dedup: "3"}, {dest: "b", dedup: "4"}}
+	dests, groups := byDest(msgs, "q")
+	if !reflect.DeepEqual(dests, []string{"q", "b"}) {
+		t.Errorf("dests %v", dests)
+	}
+	want := map[string][]string{"q": {"1", "3"}, "b": {"2", "4"}}
+	for d, ids := range want {
+		var got []string
+		for _, m := range groups[d] {
+			got = append(got, m.dedup)
+		}
+		if !reflect.DeepEqual(got, ids) {
+			t.Errorf("%s got %v, want %v", d, got, ids)
+		}
+	}
+}
+
+func TestDedupID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"10-2", "10-2"},
+		{strings.Repeat("a", 128), strings.Repeat("a", 128)},
+		{strings.Repeat("a", 129), "c12cb024a2e5551cca0e08fce8f1c5e314555cc3fef6329ee994a3db752166ae"},
+	}
+	for _, tt := range tests {
+		if got := dedupID(tt.id); got != tt.want {
+			t.Errorf("dedupID(%s) = %s, want %s", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		mode string
+		want []string
+		err  bool
+	}{
+		{"drop", []string{"0", "2"}, false},
+		{"fail", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			msgs := sized(3, 10)
+			msgs[1].body = strings.Repeat("x", maxBatchSize+1)
+			o := &offloader{mode: tt.mode}
+			out, err := o.fit(context.Background(), msgs)
+			if (err != nil) != tt.err {
+				t.Fatalf("error %v", err)
+			}
+			var got []string
+			for _, m := range out {
+				got = append(got, m.dedup)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewOffloader(t *testing.T) {
+	bucket := &OffloadConfig{Bucket: "algo-large"}
+	tests := []struct {
+		name string
+		mc   MsgConfig
+		want string
+	}{
+		{"fail by default", MsgConfig{}, "fail"},
+		{"s3 by default with a bucket", MsgConfig{Offload: bucket}, "s3"},
+		{"s3 by default with blocks", MsgConfig{Blocks: true, Offload: bucket}, "s3"},
+		{"drop", MsgConfig{Oversize: "drop", Blocks: true, Offload: bucket}, "drop"},
+		{"fail with a bucket", MsgConfig{Oversize: "fail", Offload: bucket}, "fail"},
+		{"fail with blocks", MsgConfig{Oversize: "fail", Blocks: true}, ""},
+		{"blocks without a bucket", MsgConfig{Blocks: true}, ""},
+		{"s3 without a bucket", MsgConfig{Oversize: "s3"}, ""},
+		{"unknown", MsgConfig{Oversize: "split"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := newOffloader(&tt.mc, sdk.Config{}, "")
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("got mode %s, want error", o.mode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if o.mode != tt.want {
+				t.Errorf("mode %s, want %s", o.mode, tt.want)
+			}
+		})
+	}
+}
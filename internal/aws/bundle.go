@@ -0,0 +1,105 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package aws
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//Bundle object layout, all integers big endian:
+//
+//	"ALGOBNDL" | version uint32 | count uint32
+//	count x { round uint64 | offset uint64 | size uint32 }
+//	msgpack blocks as returned by algod
+//
+//Offsets are from the start of the object so single blocks can be fetched with ranged GETs.
+const (
+	bundleMagic   = "ALGOBNDL"
+	bundleVersion = 1
+	bundleHdrSize = 16
+	bundleEntSize = 20
+)
+
+type bundleEntry struct {
+	round uint64
+	off   uint64
+	size  uint32
+}
+
+//bundle spools raw blocks of a round range to a local file until the range is complete
+type bundle struct {
+	first   uint64
+	last    uint64
+	path    string
+	f       *os.File
+	size    int64
+	entries []bundleEntry
+}
+
+func newBundle(dir string, name string, round uint64) (*bundle, error) {
+	name = strings.NewReplacer("/", "_", ":", "_").Replace(name)
+	path := filepath.Join(dir, fmt.Sprintf("%s-%012d.spool", name, round))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &bundle{first: round, last: round, path: path, f: f}, nil
+}
+
+func (b *bundle) add(round uint64, raw []byte) error {
+	if _, err := b.f.Write(raw); err != nil {
+		return err
+	}
+	b.entries = append(b.entries, bundleEntry{round: round, off: uint64(b.size), size: uint32(len(raw))})
+	b.size += int64(len(raw))
+	b.last = round
+	return nil
+}
+
+//header builds the index header with offsets moved past the header itself
+func (b *bundle) header() []byte {
+	hdrSize := bundleHdrSize + bundleEntSize*len(b.entries)
+	hdr := make([]byte, hdrSize)
+	copy(hdr, bundleMagic)
+	binary.BigEndian.PutUint32(hdr[8:], bundleVersion)
+	binary.BigEndian.PutUint32(hdr[12:], uint32(len(b.entries)))
+	p := hdr[bundleHdrSize:]
+	for _, e := range b.entries {
+		binary.BigEndian.PutUint64(p, e.round)
+		binary.BigEndian.PutUint64(p[8:], e.off+uint64(hdrSize))
+		binary.BigEndian.PutUint32(p[16:], e.size)
+		p = p[bundleEntSize:]
+	}
+	return hdr
+}
+
+//reader returns the whole bundle object and its size
+func (b *bundle) reader() (io.Reader, int64) {
+	hdr := b.header()
+	return io.MultiReader(bytes.NewReader(hdr), io.NewSectionReader(b.f, 0, b.size)), int64(len(hdr)) + b.size
+}
+
+//discard closes and removes the spool file
+func (b *bundle) discard() {
+	b.f.Close()
+	os.Remove(b.path)
+}
@@ -0,0 +1,141 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	sdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+//s3PointerClass marks bodies stored in S3, the format of the SQS/SNS extended client libraries
+const s3PointerClass = "software.amazon.payloadoffloading.PayloadS3Pointer"
+
+//OffloadConfig is the bucket of message bodies over the size limit
+type OffloadConfig struct {
+	Bucket string `json:"bucket"`
+	Prefix string `json:"prefix"`
+	//Endpoint overrides the S3 URL, defaults to the sink endpoint
+	Endpoint string `json:"endpoint"`
+	//PathStyle uses bucket names in the path, needed for MinIO
+	PathStyle bool `json:"pathStyle"`
+}
+
+//offloader handles messages over the SQS/SNS size limit
+type offloader struct {
+	mode string
+	cfg  *OffloadConfig
+	cl   *s3.Client
+}
+
+//newOffloader defaults to s3 when an offload bucket is configured and to fail otherwise.
+//Blocks are often over the limit and a failed block is retried forever, so fail is rejected with blocks.
+func newOffloader(mc *MsgConfig, ac sdk.Config, endpoint string) (*offloader, error) {
+	o := &offloader{mode: mc.Oversize, cfg: mc.Offload}
+	if o.mode == "" {
+		o.mode = "fail"
+		if o.cfg != nil && o.cfg.Bucket != "" {
+			o.mode = "s3"
+		}
+	}
+	switch o.mode {
+	case "fail":
+		if mc.Blocks {
+			return nil, fmt.Errorf("oversize fail would stall the stream on large blocks, use drop or s3 with blocks")
+		}
+	case "drop":
+	case "s3":
+		if o.cfg == nil || o.cfg.Bucket == "" {
+			return nil, fmt.Errorf("oversize s3 needs an offload bucket")
+		}
+		o.cfg.Prefix = strings.Trim(o.cfg.Prefix, "/")
+		if o.cfg.Endpoint != "" {
+			endpoint = o.cfg.Endpoint
+		}
+		o.cl = s3.NewFromConfig(ac, func(so *s3.Options) {
+			if endpoint != "" {
+				so.EndpointResolver = s3.EndpointResolverFromURL(endpoint)
+			}
+			so.UsePathStyle = o.cfg.PathStyle
+		})
+	default:
+		return nil, fmt.Errorf("oversize must be fail, drop or s3")
+	}
+	return o, nil
+}
+
+//msgSize counts the body and attributes like the services do
+func msgSize(m *message) int {
+	l := len(m.body)
+	for k, a := range m.attrs {
+		l += len(k) + len(a.dataType) + len(a.value)
+	}
+	return l
+}
+
+//fit makes every message fit the size limit.
+//Bodies stored in S3 are named by their hash so a retried block overwrites the same objects.
+func (o *offloader) fit(ctx context.Context, msgs []message) ([]message, error) {
+	out := msgs[:0]
+	for _, m := range msgs {
+		l := msgSize(&m)
+		if l <= maxBatchSize {
+			out = append(out, m)
+			continue
+		}
+		switch o.mode {
+		case "drop":
+			fmt.Fprintf(os.Stderr, "[WARN][AWS] message %s of %d bytes is over the size limit, dropping\n", m.dedup, l)
+			continue
+		case "fail":
+			return nil, fmt.Errorf("[AWS] message %s of %d bytes is over the size limit", m.dedup, l)
+		}
+		h := sha256.Sum256([]byte(m.body))
+		key := hex.EncodeToString(h[:]) + ".json"
+		if o.cfg.Prefix != "" {
+			key = o.cfg.Prefix + "/" + key
+		}
+		if _, err := o.cl.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      sdk.String(o.cfg.Bucket),
+			Key:         sdk.String(key),
+			Body:        bytes.NewReader([]byte(m.body)),
+			ContentType: sdk.String("application/json"),
+		}); err != nil {
+			return nil, fmt.Errorf("[AWS] offloading %s: %s", m.dedup, err)
+		}
+		ptr, err := json.Marshal([]interface{}{s3PointerClass, map[string]string{"s3BucketName": o.cfg.Bucket, "s3Key": key}})
+		if err != nil {
+			return nil, fmt.Errorf("[AWS] %s", err)
+		}
+		attrs := make(map[string]attr, len(m.attrs)+1)
+		for k, a := range m.attrs {
+			attrs[k] = a
+		}
+		attrs["ExtendedPayloadSize"] = attr{"Number", strconv.Itoa(len(m.body))}
+		m.body, m.attrs = string(ptr), attrs
+		out = append(out, m)
+	}
+	return out, nil
+}
@@ -0,0 +1,246 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/sink"
+	sdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	defaultS3Prefix = "algo"
+	defaultS3Rounds = 1000
+	manifestName    = "manifest.json"
+)
+
+type S3Config struct {
+	AwsConfig
+	Bucket string `json:"bucket"`
+	//Prefix is prepended to bundle and manifest keys
+	Prefix string `json:"prefix"`
+	//Rounds per bundle, bundles are aligned to multiples of it
+	Rounds uint64 `json:"rounds"`
+	//Dir holds the spool file of the bundle being filled, defaults to the temp dir
+	Dir string `json:"dir"`
+	//PathStyle uses bucket names in the path, needed for MinIO
+	PathStyle bool `json:"pathStyle"`
+}
+
+//manifest lists completed bundles in round order
+type manifest struct {
+	Bundles []manifestEntry `json:"bundles"`
+	Updated time.Time       `json:"updated"`
+}
+
+type manifestEntry struct {
+	First  uint64 `json:"first"`
+	Last   uint64 `json:"last"`
+	Key    string `json:"key"`
+	Blocks int    `json:"blocks"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+type s3Sink struct {
+	name string
+	cfg  *S3Config
+	cl   *s3.Client
+	up   *manager.Uploader
+	man  manifest
+	cur  *bundle
+	//last is the last round spooled or archived
+	last uint64
+}
+
+func init() {
+	sink.Register("s3", func() sink.Sink { return &s3Sink{} })
+}
+
+func (s *s3Sink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &S3Config{}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, s.cfg); err != nil {
+			return fmt.Errorf("[S3] invalid config: %s", err)
+		}
+	}
+	if s.cfg.Bucket == "" {
+		return fmt.Errorf("[S3] bucket is missing")
+	}
+	if s.cfg.Prefix == "" {
+		s.cfg.Prefix = defaultS3Prefix
+	}
+	s.cfg.Prefix = strings.Trim(s.cfg.Prefix, "/")
+	if s.cfg.Rounds == 0 {
+		s.cfg.Rounds = defaultS3Rounds
+	}
+	if s.cfg.Dir == "" {
+		s.cfg.Dir = os.TempDir()
+	}
+	ac, err := s.cfg.load(ctx)
+	if err != nil {
+		return fmt.Errorf("[S3] %s", err)
+	}
+	s.cl = s3.NewFromConfig(ac, func(o *s3.Options) {
+		if s.cfg.Endpoint != "" {
+			o.EndpointResolver = s3.EndpointResolverFromURL(s.cfg.Endpoint)
+		}
+		o.UsePathStyle = s.cfg.PathStyle
+	})
+	s.up = manager.NewUploader(s.cl)
+	if err := s.loadManifest(ctx); err != nil {
+		return err
+	}
+	if n := len(s.man.Bundles); n > 0 {
+		s.last = s.man.Bundles[n-1].Last
+	}
+	return nil
+}
+
+func (s *s3Sink) key(name string) string {
+	return s.cfg.Prefix + "/" + name
+}
+
+func (s *s3Sink) loadManifest(ctx context.Context) error {
+	out, err := s.cl.GetObject(ctx, &s3.GetObjectInput{Bucket: sdk.String(s.cfg.Bucket), Key: sdk.String(s.key(manifestName))})
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil
+		}
+		return fmt.Errorf("[S3] reading manifest: %s", err)
+	}
+	defer out.Body.Close()
+	if err := json.NewDecoder(out.Body).Decode(&s.man); err != nil {
+		return fmt.Errorf("[S3] invalid manifest: %s", err)
+	}
+	return nil
+}
+
+//HandleBlock spools the raw block and uploads the bundle once its last round arrives.
+//A failed upload keeps the bundle so the retried block only retries the upload.
+func (s *s3Sink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	round := uint64(b.Block.Round)
+	if round > s.last {
+		if s.cur != nil && round != s.cur.last+1 {
+			fmt.Fprintf(os.Stderr, "[WARN][S3][%s] round %d does not follow %d, restarting bundle\n", s.name, round, s.cur.last)
+			s.cur.discard()
+			s.cur = nil
+		}
+		if s.cur == nil {
+			bd, err := newBundle(s.cfg.Dir, s.name, round)
+			if err != nil {
+				return fmt.Errorf("[S3] %s", err)
+			}
+			s.cur = bd
+		}
+		if err := s.cur.add(round, b.BlockRaw); err != nil {
+			return fmt.Errorf("[S3] spooling block %d: %s", round, err)
+		}
+		s.last = round
+	}
+	if s.cur != nil && (s.cur.last+1)%s.cfg.Rounds == 0 {
+		return s.finish(ctx)
+	}
+	return nil
+}
+
+//finish uploads the bundle and then the manifest listing it, the bundle only
+//counts as archived once the manifest is written
+func (s *s3Sink) finish(ctx context.Context) error {
+	start := time.Now()
+	bd := s.cur
+	key := s.key(fmt.Sprintf("blocks-%012d-%012d.bin", bd.first, bd.last))
+	body, size := bd.reader()
+	h := sha256.New()
+	if _, err := s.up.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      sdk.String(s.cfg.Bucket),
+		Key:         sdk.String(key),
+		Body:        io.TeeReader(body, h),
+		ContentType: sdk.String("application/octet-stream"),
+	}); err != nil {
+		return fmt.Errorf("[S3] uploading %s: %s", key, err)
+	}
+
+	man := s.man
+	man.Bundles = append(man.Bundles[:len(man.Bundles):len(man.Bundles)], manifestEntry{
+		First:  bd.first,
+		Last:   bd.last,
+		Key:    key,
+		Blocks: len(bd.entries),
+		Size:   size,
+		Sha256: hex.EncodeToString(h.Sum(nil)),
+	})
+	man.Updated = time.Now().UTC()
+	j, err := json.Marshal(man)
+	if err != nil {
+		return fmt.Errorf("[S3] %s", err)
+	}
+	if _, err := s.cl.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      sdk.String(s.cfg.Bucket),
+		Key:         sdk.String(s.key(manifestName)),
+		Body:        bytes.NewReader(j),
+		ContentType: sdk.String("application/json"),
+	}); err != nil {
+		return fmt.Errorf("[S3] writing manifest: %s", err)
+	}
+	s.man = man
+	bd.discard()
+	s.cur = nil
+	fmt.Fprintf(os.Stderr, "[INFO][S3][%s] Bundle %s uploaded in %s (%d blocks, %d bytes)\n", s.name, key, time.Since(start), len(bd.entries), size)
+	return nil
+}
+
+func (s *s3Sink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	return nil
+}
+
+//Flush leaves an incomplete bundle out, its rounds are fetched again after restart
+func (s *s3Sink) Flush(ctx context.Context) error {
+	if s.cur != nil {
+		fmt.Fprintf(os.Stderr, "[INFO][S3][%s] Discarding incomplete bundle %d-%d\n", s.name, s.cur.first, s.cur.last)
+		s.cur.discard()
+		s.cur = nil
+	}
+	return nil
+}
+
+func (s *s3Sink) Close(ctx context.Context) error {
+	return nil
+}
+
+//LastCommittedRound is the last round of the last bundle in the manifest
+func (s *s3Sink) LastCommittedRound(ctx context.Context) (uint64, error) {
+	if len(s.man.Bundles) == 0 {
+		return 0, sink.ErrNoCheckpoint
+	}
+	return s.man.Bundles[len(s.man.Bundles)-1].Last, nil
+}
@@ -0,0 +1,149 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/sink"
+	sdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+type SnsConfig struct {
+	AwsConfig
+	MsgConfig
+	//Topic is the topic ARN, ARNs ending with .fifo are FIFO topics
+	//where txns of each sender account share a message group
+	Topic string `json:"topic"`
+}
+
+type snsSink struct {
+	sink.NoCheckpoint
+	name string
+	cfg  *SnsConfig
+	cl   *sns.Client
+	off  *offloader
+}
+
+func init() {
+	sink.Register("sns", func() sink.Sink { return &snsSink{} })
+}
+
+func (s *snsSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &SnsConfig{}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, s.cfg); err != nil {
+			return fmt.Errorf("[SNS] invalid config: %s", err)
+		}
+	}
+	if s.cfg.Topic == "" {
+		return fmt.Errorf("[SNS] topic is missing")
+	}
+	ac, err := s.cfg.load(ctx)
+	if err != nil {
+		return fmt.Errorf("[SNS] %s", err)
+	}
+	s.cl = sns.NewFromConfig(ac, func(o *sns.Options) {
+		if s.cfg.Endpoint != "" {
+			o.EndpointResolver = sns.EndpointResolverFromURL(s.cfg.Endpoint)
+		}
+	})
+	if s.off, err = newOffloader(&s.cfg.MsgConfig, ac, s.cfg.Endpoint); err != nil {
+		return fmt.Errorf("[SNS] %s", err)
+	}
+	if _, err := s.cl.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{TopicArn: sdk.String(s.cfg.Topic)}); err != nil {
+		return fmt.Errorf("[SNS] topic %s: %s", s.cfg.Topic, err)
+	}
+	return nil
+}
+
+func (s *snsSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	start := time.Now()
+	msgs := blockMessages(b, s.cfg.Blocks)
+	if err := s.publish(ctx, msgs); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "[INFO][SNS][%s] Block %d published in %s (%d msg)\n", s.name, uint64(b.Block.Round), time.Since(start), len(msgs))
+	return nil
+}
+
+func (s *snsSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	if !s.cfg.Status {
+		return nil
+	}
+	if err := s.publish(ctx, statusMessages(status)); err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][SNS] %s\n", err)
+		return err
+	}
+	return nil
+}
+
+//publish fails on the first failed entry so the whole block is retried,
+//FIFO topics drop the repeated messages by their deduplication IDs
+func (s *snsSink) publish(ctx context.Context, msgs []message) error {
+	msgs, err := s.off.fit(ctx, msgs)
+	if err != nil {
+		return err
+	}
+	arns, groups := byDest(msgs, s.cfg.Topic)
+	for _, arn := range arns {
+		fifo := strings.HasSuffix(arn, ".fifo")
+		for _, batch := range batches(groups[arn]) {
+			entries := make([]types.PublishBatchRequestEntry, 0, len(batch))
+			for i, m := range batch {
+				e := types.PublishBatchRequestEntry{
+					Id:                sdk.String(strconv.Itoa(i)),
+					Message:           sdk.String(m.body),
+					MessageAttributes: make(map[string]types.MessageAttributeValue, len(m.attrs)),
+				}
+				for k, a := range m.attrs {
+					e.MessageAttributes[k] = types.MessageAttributeValue{DataType: sdk.String(a.dataType), StringValue: sdk.String(a.value)}
+				}
+				if fifo {
+					e.MessageGroupId = sdk.String(m.group)
+					e.MessageDeduplicationId = sdk.String(dedupID(m.dedup))
+				}
+				entries = append(entries, e)
+			}
+			out, err := s.cl.PublishBatch(ctx, &sns.PublishBatchInput{TopicArn: sdk.String(arn), PublishBatchRequestEntries: entries})
+			if err != nil {
+				return fmt.Errorf("[SNS] publish: %s", err)
+			}
+			if len(out.Failed) > 0 {
+				f := out.Failed[0]
+				return fmt.Errorf("[SNS] %d messages failed, first %s: %s", len(out.Failed), sdk.ToString(f.Code), sdk.ToString(f.Message))
+			}
+		}
+	}
+	return nil
+}
+
+func (s *snsSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *snsSink) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/sink"
+	sdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+type SqsConfig struct {
+	AwsConfig
+	MsgConfig
+	//Queue is a queue URL or name, names ending with .fifo are FIFO queues
+	//where txns of each sender account share a message group
+	Queue string `json:"queue"`
+}
+
+type sqsSink struct {
+	sink.NoCheckpoint
+	name string
+	cfg  *SqsConfig
+	cl   *sqs.Client
+	off  *offloader
+	mu   sync.Mutex
+	urls map[string]string
+}
+
+func init() {
+	sink.Register("sqs", func() sink.Sink { return &sqsSink{} })
+}
+
+func (s *sqsSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &SqsConfig{}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, s.cfg); err != nil {
+			return fmt.Errorf("[SQS] invalid config: %s", err)
+		}
+	}
+	if s.cfg.Queue == "" {
+		return fmt.Errorf("[SQS] queue is missing")
+	}
+	ac, err := s.cfg.load(ctx)
+	if err != nil {
+		return fmt.Errorf("[SQS] %s", err)
+	}
+	s.cl = sqs.NewFromConfig(ac, func(o *sqs.Options) {
+		if s.cfg.Endpoint != "" {
+			o.EndpointResolver = sqs.EndpointResolverFromURL(s.cfg.Endpoint)
+		}
+	})
+	if s.off, err = newOffloader(&s.cfg.MsgConfig, ac, s.cfg.Endpoint); err != nil {
+		return fmt.Errorf("[SQS] %s", err)
+	}
+	s.urls = make(map[string]string)
+	if _, err := s.queueURL(ctx, s.cfg.Queue); err != nil {
+		return err
+	}
+	return nil
+}
+
+//queueURL resolves queue names, rules may route to other queues by name or URL
+func (s *sqsSink) queueURL(ctx context.Context, queue string) (string, error) {
+	if strings.HasPrefix(queue, "https://") || strings.HasPrefix(queue, "http://") {
+		return queue, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.urls[queue]; ok {
+		return u, nil
+	}
+	out, err := s.cl.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: sdk.String(queue)})
+	if err != nil {
+		return "", fmt.Errorf("[SQS] queue %s: %s", queue, err)
+	}
+	s.urls[queue] = *out.QueueUrl
+	return *out.QueueUrl, nil
+}
+
+func (s *sqsSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	start := time.Now()
+	msgs := blockMessages(b, s.cfg.Blocks)
+	if err := s.send(ctx, msgs); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "[INFO][SQS][%s] Block %d sent in %s (%d msg)\n", s.name, uint64(b.Block.Round), time.Since(start), len(msgs))
+	return nil
+}
+
+func (s *sqsSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	if !s.cfg.Status {
+		return nil
+	}
+	if err := s.send(ctx, statusMessages(status)); err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][SQS] %s\n", err)
+		return err
+	}
+	return nil
+}
+
+//send fails on the first failed entry so the whole block is retried,
+//FIFO queues drop the repeated messages by their deduplication IDs
+func (s *sqsSink) send(ctx context.Context, msgs []message) error {
+	msgs, err := s.off.fit(ctx, msgs)
+	if err != nil {
+		return err
+	}
+	dests, groups := byDest(msgs, s.cfg.Queue)
+	for _, d := range dests {
+		url, err := s.queueURL(ctx, d)
+		if err != nil {
+			return err
+		}
+		fifo := strings.HasSuffix(url, ".fifo")
+		for _, batch := range batches(groups[d]) {
+			entries := make([]types.SendMessageBatchRequestEntry, 0, len(batch))
+			for i, m := range batch {
+				e := types.SendMessageBatchRequestEntry{
+					Id:                sdk.String(strconv.Itoa(i)),
+					MessageBody:       sdk.String(m.body),
+					MessageAttributes: make(map[string]types.MessageAttributeValue, len(m.attrs)),
+				}
+				for k, a := range m.attrs {
+					e.MessageAttributes[k] = types.MessageAttributeValue{DataType: sdk.String(a.dataType), StringValue: sdk.String(a.value)}
+				}
+				if fifo {
+					e.MessageGroupId = sdk.String(m.group)
+					e.MessageDeduplicationId = sdk.String(dedupID(m.dedup))
+				}
+				entries = append(entries, e)
+			}
+			out, err := s.cl.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{QueueUrl: sdk.String(url), Entries: entries})
+			if err != nil {
+				return fmt.Errorf("[SQS] send: %s", err)
+			}
+			if len(out.Failed) > 0 {
+				f := out.Failed[0]
+				return fmt.Errorf("[SQS] %d messages failed, first %s: %s", len(out.Failed), sdk.ToString(f.Code), sdk.ToString(f.Message))
+			}
+		}
+	}
+	return nil
+}
+
+func (s *sqsSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *sqsSink) Close(ctx context.Context) error {
+	return nil
+}
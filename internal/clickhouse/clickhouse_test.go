@@ -0,0 +1,81 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package clickhouse
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+var (
+	alice = types.Address{1}
+	bob   = types.Address{2}
+)
+
+func testBlock(round uint64, txns ...types.Transaction) *sink.Block {
+	bw := &algod.BlockWrap{Block: &types.Block{BlockHeader: types.BlockHeader{Round: types.Round(round), TimeStamp: 1660000000}}}
+	b := &sink.Block{BlockWrap: bw, Msgs: []sink.Msg{{}}}
+	for i, tx := range txns {
+		stxn := types.SignedTxnInBlock{SignedTxnWithAD: types.SignedTxnWithAD{SignedTxn: types.SignedTxn{Txn: tx}}}
+		bw.Block.Payset = append(bw.Block.Payset, stxn)
+		b.Txns = append(b.Txns, &sink.Tx{TxWrap: &algod.TxWrap{TxId: "T" + string(rune('A'+i)), Txn: &stxn, Round: round, Intra: i}})
+	}
+	return b
+}
+
+func TestHandleBlockRows(t *testing.T) {
+	pay := types.Transaction{Type: types.PaymentTx}
+	pay.Sender, pay.Receiver, pay.Amount, pay.Fee = alice, bob, 5, 1000
+	pay.Note = []byte("hello world")
+	axfer := types.Transaction{Type: types.AssetTransferTx}
+	axfer.Sender, axfer.AssetReceiver, axfer.XferAsset, axfer.AssetAmount = bob, alice, 7, 42
+	axfer.Group = types.Digest{1}
+
+	s := &clickHouseSink{cfg: &ClickHouseConfig{Batch: 10, NoteBytes: 4}, last: 4}
+	ctx := context.Background()
+	//round 4 is at the checkpoint and the retried round 5 is queued once
+	for _, b := range []*sink.Block{testBlock(4, pay), testBlock(5, pay, axfer), testBlock(5, pay, axfer)} {
+		if err := s.HandleBlock(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := time.Unix(1660000000, 0).UTC()
+	want := [][]interface{}{
+		{uint64(5), uint32(0), "TA", "pay", alice.String(), bob.String(), uint64(5), uint64(0), uint64(0), uint64(1000), "hell", "", ts},
+		{uint64(5), uint32(1), "TB", "axfer", bob.String(), alice.String(), uint64(42), uint64(7), uint64(0), uint64(0), "", "AQAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", ts},
+	}
+	if len(s.txns) != len(want) {
+		t.Fatalf("%d txn rows, want %d", len(s.txns), len(want))
+	}
+	for i := range want {
+		if got := txnValues(&s.txns[i]); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("txn row %d\n got %#v\nwant %#v", i, got, want[i])
+		}
+	}
+	if len(s.blocks) != 1 {
+		t.Fatalf("%d block rows, want 1", len(s.blocks))
+	}
+	if got, want := s.blocks[0].values(), []interface{}{uint64(5), ts, uint32(2)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("block row %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package clickhouse
+
+//ReplacingMergeTree collapses rows with the same sorting key during merges,
+//rows inserted again after a restart or a failed flush are deduplicated by (round, intra)
+const txnsTable = `
+CREATE TABLE IF NOT EXISTS %s (
+	round    UInt64,
+	intra    UInt32,
+	txid     String,
+	type     LowCardinality(String),
+	sender   String,
+	receiver String,
+	amount   UInt64,
+	asset    UInt64,
+	app      UInt64,
+	fee      UInt64,
+	note     String,
+	grp      String,
+	ts       DateTime('UTC')
+) ENGINE = ReplacingMergeTree
+PARTITION BY toYYYYMM(ts)
+ORDER BY (round, intra)`
+
+//blocks rows are inserted after the txns of a batch and serve as the resume checkpoint
+const blocksTable = `
+CREATE TABLE IF NOT EXISTS %s (
+	round     UInt64,
+	ts        DateTime('UTC'),
+	txn_count UInt32
+) ENGINE = ReplacingMergeTree
+ORDER BY round`
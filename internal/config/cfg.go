@@ -16,11 +16,11 @@
 package config
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 
 	"github.com/algonode/algostreamer/internal/algod"
-	"github.com/algonode/algostreamer/internal/rdb"
 	"github.com/algonode/algostreamer/internal/rego"
 	"github.com/algonode/algostreamer/internal/utils"
 )
@@ -30,15 +30,16 @@ var firstRound = flag.Int64("r", -1, "first round to start [-1 = latest]")
 var lastRound = flag.Int64("l", -1, "last round to read [-1 = no limit]")
 var simpleFlag = flag.Bool("s", false, "simple mode - just sending blocks in JSON format to stdout")
 
-type SinksCfg struct {
-	Redis *rdb.RedisConfig `json:"redis"`
-}
+//SinksCfg maps sink names to their raw config, each sink parses its own section
+type SinksCfg map[string]json.RawMessage
 
 type SteramerConfig struct {
 	Algod  *algod.AlgoConfig `json:"algod"`
 	Sinks  SinksCfg          `json:"sinks"`
 	Rego   *rego.OpaConfig   `json:"opa"`
 	Stdout bool              `json:"stdout"`
+	//Debug is the listen address of the HTTP server with expvar stats on /debug/vars
+	Debug string `json:"debug"`
 }
 
 var defaultConfig = SteramerConfig{}
@@ -59,5 +60,25 @@ func LoadConfig() (cfg SteramerConfig, err error) {
 	cfg.Algod.LRound = *lastRound
 	cfg.Stdout = *simpleFlag
 
+	//simple mode replaces all configured sinks with stdout
+	if cfg.Stdout {
+		cfg.Sinks = SinksCfg{"stdout": nil}
+	}
+	if len(cfg.Sinks) == 0 {
+		return cfg, fmt.Errorf("[CFG] Configure at least one sink")
+	}
+
 	return cfg, err
 }
+
+//LoadRegoConfig loads just the OPA section of a config file
+func LoadRegoConfig(filename string) (*rego.OpaConfig, error) {
+	var cfg SteramerConfig
+	if err := utils.LoadJSONCFromFile(filename, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Rego == nil {
+		return nil, fmt.Errorf("[CFG] Missing opa config")
+	}
+	return cfg.Rego, nil
+}
@@ -0,0 +1,266 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/sink"
+)
+
+const (
+	defaultRounds = 1000
+	blocksPrefix  = "blocks"
+	txnsPrefix    = "txns"
+)
+
+type FileConfig struct {
+	Dir string `json:"dir"`
+	//Format of block files - json (default) for NDJSON or msgp for raw algod msgpack
+	Format string `json:"format"`
+	//Txns writes NDJSON txn files next to block files
+	Txns bool `json:"txns"`
+	//Rounds per file, files cover aligned ranges like 1000-1999
+	Rounds uint64 `json:"rounds"`
+	//MaxSize rolls a file early once it has this many MB on disk
+	MaxSize int64 `json:"maxSize"`
+	//Compress is gzip, zstd or empty
+	Compress string `json:"compress"`
+}
+
+type fileSink struct {
+	name   string
+	cfg    *FileConfig
+	blocks *roller
+	txns   *roller
+	//last is the last round written, older rounds are skipped after resume
+	last uint64
+}
+
+func init() {
+	sink.Register("file", func() sink.Sink { return &fileSink{} })
+}
+
+func (s *fileSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &FileConfig{}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, s.cfg); err != nil {
+			return fmt.Errorf("[FILE] invalid config: %s", err)
+		}
+	}
+	if s.cfg.Dir == "" {
+		s.cfg.Dir = "."
+	}
+	if s.cfg.Rounds == 0 {
+		s.cfg.Rounds = defaultRounds
+	}
+	switch s.cfg.Format {
+	case "":
+		s.cfg.Format = "json"
+	case "json", "msgp":
+	default:
+		return fmt.Errorf("[FILE] format must be json or msgp")
+	}
+	ext, ok := compressExt[s.cfg.Compress]
+	if !ok {
+		return fmt.Errorf("[FILE] compress must be gzip, zstd or empty")
+	}
+	if err := os.MkdirAll(s.cfg.Dir, 0755); err != nil {
+		return fmt.Errorf("[FILE] %s", err)
+	}
+
+	blockExt := ".ndjson"
+	if s.cfg.Format == "msgp" {
+		blockExt = ".msgp"
+	}
+	s.blocks = &roller{dir: s.cfg.Dir, prefix: blocksPrefix, ext: blockExt + ext, format: s.cfg.Format, compress: s.cfg.Compress}
+	if s.cfg.Txns {
+		s.txns = &roller{dir: s.cfg.Dir, prefix: txnsPrefix, ext: ".ndjson" + ext, format: "json", compress: s.cfg.Compress}
+	}
+
+	//files left open by a crash may end mid record, their rounds are fetched again
+	partial, _ := filepath.Glob(filepath.Join(s.cfg.Dir, "*"+partialExt))
+	for _, p := range partial {
+		discarded := fmt.Sprintf("%s.%d.discarded", p, time.Now().Unix())
+		fmt.Fprintf(os.Stderr, "[WARN][FILE][%s] unfinished file %s renamed to %s\n", s.name, p, discarded)
+		if err := os.Rename(p, discarded); err != nil {
+			return fmt.Errorf("[FILE] %s", err)
+		}
+	}
+	last, err := s.LastCommittedRound(ctx)
+	if err == nil {
+		s.last = last
+	}
+	return nil
+}
+
+//HandleBlock appends the block and its txns to the current files and rolls them
+//at the round range boundary or when the size limit is reached.
+//A retry after a failed txns write does not append the block again.
+func (s *fileSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	round := uint64(b.Block.Round)
+	if s.last > 0 && round <= s.last {
+		return nil
+	}
+	if err := s.open(round); err != nil {
+		return err
+	}
+
+	var recs [][]byte
+	if s.cfg.Format == "msgp" {
+		if len(b.Msgs) > 0 {
+			recs = append(recs, b.BlockRaw)
+		}
+	} else {
+		for i := range b.Msgs {
+			j, err := ndjson(b.Msgs[i].Encode(b.Payload()))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[!ERR][FILE] %s\n", err)
+				continue
+			}
+			recs = append(recs, j)
+		}
+	}
+	if err := s.blocks.write(round, recs); err != nil {
+		return err
+	}
+
+	if s.txns != nil {
+		recs = recs[:0]
+		for _, tx := range b.Txns {
+			j, err := ndjson(tx.Encode(tx.TxWrap))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[!ERR][FILE] %s\n", err)
+				continue
+			}
+			recs = append(recs, j)
+		}
+		if err := s.txns.write(round, recs); err != nil {
+			return err
+		}
+	}
+	s.last = round
+
+	full := s.cfg.MaxSize > 0 && (s.blocks.size() >= s.cfg.MaxSize<<20 || s.txns.size() >= s.cfg.MaxSize<<20)
+	if (round+1)%s.cfg.Rounds == 0 || full {
+		return s.roll()
+	}
+	return nil
+}
+
+func (s *fileSink) open(round uint64) error {
+	if err := s.blocks.open(round); err != nil {
+		return err
+	}
+	if s.txns != nil {
+		return s.txns.open(round)
+	}
+	return nil
+}
+
+//roll finishes block and txn files together so they cover the same rounds
+func (s *fileSink) roll() error {
+	name, err := s.blocks.finish()
+	if err != nil {
+		return err
+	}
+	if s.txns != nil {
+		if _, err := s.txns.finish(); err != nil {
+			return err
+		}
+	}
+	if name != "" {
+		fmt.Fprintf(os.Stderr, "[INFO][FILE][%s] %s written\n", s.name, name)
+	}
+	return nil
+}
+
+//ndjson keeps each record on a single line
+func ndjson(j []byte, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, j); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func (s *fileSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	return nil
+}
+
+func (s *fileSink) Flush(ctx context.Context) error {
+	if err := s.blocks.flush(); err != nil {
+		return err
+	}
+	return s.txns.flush()
+}
+
+//Close finishes the current files, a restart continues with a new file after them
+func (s *fileSink) Close(ctx context.Context) error {
+	return s.roll()
+}
+
+//LastCommittedRound is the last round of finished block files
+func (s *fileSink) LastCommittedRound(ctx context.Context) (uint64, error) {
+	files, err := filepath.Glob(filepath.Join(s.cfg.Dir, blocksPrefix+"-*"+s.blocks.ext))
+	if err != nil {
+		return 0, fmt.Errorf("[FILE] %s", err)
+	}
+	var (
+		last  uint64
+		found bool
+	)
+	for _, f := range files {
+		_, l, ok := parseRange(filepath.Base(f), blocksPrefix, s.blocks.ext)
+		if !ok {
+			continue
+		}
+		if !found || l > last {
+			last, found = l, true
+		}
+	}
+	if !found {
+		return 0, sink.ErrNoCheckpoint
+	}
+	return last, nil
+}
+
+//parseRange reads the round range from <prefix>-<first>-<last><ext>
+func parseRange(name, prefix, ext string) (uint64, uint64, bool) {
+	if !strings.HasPrefix(name, prefix+"-") || !strings.HasSuffix(name, ext) {
+		return 0, 0, false
+	}
+	a := strings.Split(strings.TrimSuffix(strings.TrimPrefix(name, prefix+"-"), ext), "-")
+	if len(a) != 2 {
+		return 0, 0, false
+	}
+	first, err1 := strconv.ParseUint(a[0], 10, 64)
+	last, err2 := strconv.ParseUint(a[1], 10, 64)
+	return first, last, err1 == nil && err2 == nil
+}
@@ -0,0 +1,38 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package file
+
+import "testing"
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name        string
+		first, last uint64
+		ok          bool
+	}{
+		{"blocks-000000001000-000000001999.ndjson", 1000, 1999, true},
+		{"blocks-000000001000-000000001999.ndjson.gz", 0, 0, false},
+		{"blocks-000000001000.ndjson.partial", 0, 0, false},
+		{"txns-000000001000-000000001999.ndjson", 0, 0, false},
+		{"blocks-x-000000001999.ndjson", 0, 0, false},
+	}
+	for _, tt := range tests {
+		first, last, ok := parseRange(tt.name, blocksPrefix, ".ndjson")
+		if ok != tt.ok || (ok && (first != tt.first || last != tt.last)) {
+			t.Errorf("parseRange(%s) = %d, %d, %v", tt.name, first, last, ok)
+		}
+	}
+}
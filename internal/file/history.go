@@ -0,0 +1,116 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package file
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/klauspost/compress/zstd"
+)
+
+//errStop ends a scan once the requested rounds are read
+var errStop = fmt.Errorf("stop")
+
+//ReadBlocks reads finished block files covering the requested rounds,
+//rounds of the file being written are not readable until it is rolled
+func (s *fileSink) ReadBlocks(ctx context.Context, from uint64, to uint64, fn func(*algod.BlockWrap) error) error {
+	type span struct {
+		path        string
+		first, last uint64
+	}
+	files, err := filepath.Glob(filepath.Join(s.cfg.Dir, blocksPrefix+"-*"+s.blocks.ext))
+	if err != nil {
+		return fmt.Errorf("[FILE] %s", err)
+	}
+	var spans []span
+	for _, f := range files {
+		first, last, ok := parseRange(filepath.Base(f), blocksPrefix, s.blocks.ext)
+		if !ok || last < from || (to > 0 && first > to) {
+			continue
+		}
+		spans = append(spans, span{f, first, last})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].first < spans[j].first })
+
+	next := from
+	for _, sp := range spans {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := s.scanFile(sp.path, func(bw *algod.BlockWrap) error {
+			round := uint64(bw.Block.Round)
+			if to > 0 && round > to {
+				return errStop
+			}
+			if round < next {
+				return nil
+			}
+			next = round + 1
+			return fn(bw)
+		})
+		if err == errStop {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//scanFile decodes blocks of a finished file in the sink's format and compression
+func (s *fileSink) scanFile(path string, fn func(*algod.BlockWrap) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("[FILE] %s", err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	switch s.cfg.Compress {
+	case "gzip":
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("[FILE] %s: %s", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	case "zstd":
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("[FILE] %s: %s", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	src := filepath.Base(path)
+	if s.cfg.Format == "msgp" {
+		err = algod.ScanMsgpBlocks(r, src, fn)
+	} else {
+		err = algod.ScanJSONBlocks(r, src, fn)
+	}
+	if err != nil && err != errStop {
+		//keep errors of fn comparable for the caller
+		return fmt.Errorf("[FILE] %s: %w", path, err)
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+	"github.com/algorand/go-algorand-sdk/encoding/msgpack"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+func testBlock(round uint64) *sink.Block {
+	blk := types.Block{BlockHeader: types.BlockHeader{Round: types.Round(round), GenesisID: "testnet-v1.0"}}
+	bw := &algod.BlockWrap{Block: &blk, BlockRaw: msgpack.Encode(models.BlockResponse{Block: blk})}
+	return &sink.Block{BlockWrap: bw, Msgs: []sink.Msg{{}}}
+}
+
+func read(t *testing.T, s *fileSink, from, to uint64) []uint64 {
+	var got []uint64
+	err := s.ReadBlocks(context.Background(), from, to, func(bw *algod.BlockWrap) error {
+		if bw.Block.GenesisID != "testnet-v1.0" {
+			t.Errorf("round %d decoded as %+v", bw.Block.Round, bw.Block.BlockHeader)
+		}
+		got = append(got, uint64(bw.Block.Round))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestReadBlocks(t *testing.T) {
+	for _, format := range []string{"json", "msgp"} {
+		for _, compress := range []string{"", "zstd"} {
+			t.Run(format+compress, func(t *testing.T) {
+				ctx := context.Background()
+				cfg, _ := json.Marshal(&FileConfig{Dir: t.TempDir(), Format: format, Rounds: 4, Compress: compress, Txns: true})
+				s := &fileSink{}
+				if err := s.Init(ctx, "test", cfg); err != nil {
+					t.Fatal(err)
+				}
+				for round := uint64(1); round <= 9; round++ {
+					if err := s.HandleBlock(ctx, testBlock(round)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				//8 and 9 are in the open file
+				tests := []struct {
+					from, to uint64
+					want     []uint64
+				}{
+					{1, 0, []uint64{1, 2, 3, 4, 5, 6, 7}},
+					{2, 5, []uint64{2, 3, 4, 5}},
+					{6, 0, []uint64{6, 7}},
+					{8, 0, nil},
+				}
+				for _, tt := range tests {
+					if got := read(t, s, tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+						t.Errorf("ReadBlocks(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+					}
+				}
+
+				if err := s.Close(ctx); err != nil {
+					t.Fatal(err)
+				}
+				if got := read(t, s, 6, 0); !reflect.DeepEqual(got, []uint64{6, 7, 8, 9}) {
+					t.Errorf("after close got %v", got)
+				}
+				last, err := s.LastCommittedRound(ctx)
+				if err != nil || last != 9 {
+					t.Errorf("LastCommittedRound = %d, %v", last, err)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	partialExt = ".partial"
+	indexExt   = ".idx"
+)
+
+var compressExt = map[string]string{"": "", "gzip": ".gz", "zstd": ".zst"}
+
+//index is written next to every finished file
+type index struct {
+	File        string       `json:"file"`
+	First       uint64       `json:"first"`
+	Last        uint64       `json:"last"`
+	Records     int          `json:"records"`
+	Format      string       `json:"format"`
+	Compression string       `json:"compression,omitempty"`
+	Rounds      []indexRound `json:"rounds"`
+}
+
+//indexRound points to the first record of a round, offsets are in uncompressed bytes
+type indexRound struct {
+	Round   uint64 `json:"round"`
+	Offset  int64  `json:"offset"`
+	Records int    `json:"records"`
+}
+
+//countWriter counts bytes that reach the disk
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+//roller writes one series of files named by their round range
+type roller struct {
+	dir      string
+	prefix   string
+	ext      string
+	format   string
+	compress string
+
+	f   *os.File
+	cw  *countWriter
+	zw  io.WriteCloser
+	bw  *bufio.Writer
+	off int64
+	idx *index
+	err error
+}
+
+func (r *roller) partialName() string {
+	return filepath.Join(r.dir, fmt.Sprintf("%s-%012d%s%s", r.prefix, r.idx.First, r.ext, partialExt))
+}
+
+func (r *roller) open(round uint64) error {
+	if r.f != nil {
+		return nil
+	}
+	r.idx = &index{First: round, Format: r.format, Compression: r.compress}
+	f, err := os.OpenFile(r.partialName(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("[FILE] %s", err)
+	}
+	r.f = f
+	r.cw = &countWriter{w: f}
+	switch r.compress {
+	case "gzip":
+		r.zw = gzip.NewWriter(r.cw)
+	case "zstd":
+		zw, err := zstd.NewWriter(r.cw)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("[FILE] %s", err)
+		}
+		r.zw = zw
+	default:
+		r.zw = nopCloser{r.cw}
+	}
+	r.bw = bufio.NewWriterSize(r.zw, 1<<20)
+	r.off = 0
+	return nil
+}
+
+//write appends the records of a round once, a round written already is skipped
+//so a block retried after the other series failed is not duplicated.
+//A failed write leaves a partial record behind, the roller keeps failing
+//and the partial file is rewritten from the last finished round after a restart.
+func (r *roller) write(round uint64, recs [][]byte) error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.idx.Rounds) > 0 && round <= r.idx.Last {
+		return nil
+	}
+	off := r.off
+	for _, rec := range recs {
+		n, err := r.bw.Write(rec)
+		r.off += int64(n)
+		if err != nil {
+			r.err = fmt.Errorf("[FILE] %s", err)
+			return r.err
+		}
+	}
+	r.idx.Rounds = append(r.idx.Rounds, indexRound{Round: round, Offset: off, Records: len(recs)})
+	r.idx.Last = round
+	r.idx.Records += len(recs)
+	return nil
+}
+
+//size is the number of bytes on disk, buffered data is not counted
+func (r *roller) size() int64 {
+	if r == nil || r.cw == nil {
+		return 0
+	}
+	return r.cw.n
+}
+
+func (r *roller) flush() error {
+	if r == nil || r.f == nil {
+		return nil
+	}
+	if err := r.bw.Flush(); err != nil {
+		return fmt.Errorf("[FILE] %s", err)
+	}
+	return nil
+}
+
+//finish closes the file, renames it to its round range and writes the index
+func (r *roller) finish() (string, error) {
+	if r == nil || r.f == nil {
+		return "", nil
+	}
+	//a file with a partial record stays unfinished
+	if r.err != nil {
+		return "", r.err
+	}
+	defer func() { r.f, r.cw, r.zw, r.bw = nil, nil, nil, nil }()
+	if err := r.bw.Flush(); err != nil {
+		r.f.Close()
+		return "", fmt.Errorf("[FILE] %s", err)
+	}
+	if err := r.zw.Close(); err != nil {
+		r.f.Close()
+		return "", fmt.Errorf("[FILE] %s", err)
+	}
+	if err := r.f.Sync(); err != nil {
+		r.f.Close()
+		return "", fmt.Errorf("[FILE] %s", err)
+	}
+	if err := r.f.Close(); err != nil {
+		return "", fmt.Errorf("[FILE] %s", err)
+	}
+
+	r.idx.File = fmt.Sprintf("%s-%012d-%012d%s", r.prefix, r.idx.First, r.idx.Last, r.ext)
+	final := filepath.Join(r.dir, r.idx.File)
+	j, err := json.Marshal(r.idx)
+	if err != nil {
+		return "", fmt.Errorf("[FILE] %s", err)
+	}
+	//index first, a finished file always has one
+	if err := os.WriteFile(final+indexExt, j, 0644); err != nil {
+		return "", fmt.Errorf("[FILE] %s", err)
+	}
+	if err := os.Rename(r.partialName(), final); err != nil {
+		return "", fmt.Errorf("[FILE] %s", err)
+	}
+	return r.idx.File, nil
+}
@@ -0,0 +1,160 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestRoller(t *testing.T) {
+	tests := []struct {
+		compress string
+		file     string
+	}{
+		{"", "blocks-000000000010-000000000012.ndjson"},
+		{"gzip", "blocks-000000000010-000000000012.ndjson.gz"},
+		{"zstd", "blocks-000000000010-000000000012.ndjson.zst"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.compress, func(t *testing.T) {
+			dir := t.TempDir()
+			r := &roller{dir: dir, prefix: blocksPrefix, ext: ".ndjson" + compressExt[tt.compress], format: "json", compress: tt.compress}
+			if err := r.open(10); err != nil {
+				t.Fatal(err)
+			}
+			recs := map[uint64][][]byte{
+				10: {[]byte("a\n"), []byte("bb\n")},
+				11: nil,
+				12: {[]byte("ccc\n")},
+			}
+			for round := uint64(10); round <= 12; round++ {
+				if err := r.write(round, recs[round]); err != nil {
+					t.Fatal(err)
+				}
+				//a retried round is not written twice
+				if err := r.write(round, recs[round]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			name, err := r.finish()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != tt.file {
+				t.Errorf("file %s, want %s", name, tt.file)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "blocks-000000000010.ndjson"+compressExt[tt.compress]+partialExt)); !os.IsNotExist(err) {
+				t.Errorf("partial file left behind: %v", err)
+			}
+
+			f, err := os.Open(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			var rd io.Reader = f
+			switch tt.compress {
+			case "gzip":
+				if rd, err = gzip.NewReader(f); err != nil {
+					t.Fatal(err)
+				}
+			case "zstd":
+				zr, err := zstd.NewReader(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer zr.Close()
+				rd = zr
+			}
+			data, err := io.ReadAll(rd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "a\nbb\nccc\n" {
+				t.Errorf("content %q", data)
+			}
+
+			j, err := os.ReadFile(filepath.Join(dir, name+indexExt))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var idx index
+			if err := json.Unmarshal(j, &idx); err != nil {
+				t.Fatal(err)
+			}
+			want := index{File: tt.file, First: 10, Last: 12, Records: 3, Format: "json", Compression: tt.compress,
+				Rounds: []indexRound{{10, 0, 2}, {11, 5, 0}, {12, 5, 1}}}
+			if !reflect.DeepEqual(idx, want) {
+				t.Errorf("index %+v, want %+v", idx, want)
+			}
+		})
+	}
+}
+
+//failWriter fails every write once broken
+type failWriter struct {
+	broken bool
+	buf    bytes.Buffer
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.broken {
+		return 0, errors.New("disk full")
+	}
+	return w.buf.Write(p)
+}
+
+func TestRollerWriteError(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "blocks.partial"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := &failWriter{}
+	r := &roller{idx: &index{}, f: f, cw: &countWriter{w: w}}
+	r.zw = nopCloser{r.cw}
+	//records larger than the buffer go straight to the writer
+	r.bw = bufio.NewWriterSize(r.zw, 16)
+	rec := bytes.Repeat([]byte("x"), 32)
+	if err := r.write(1, [][]byte{rec}); err != nil {
+		t.Fatal(err)
+	}
+	w.broken = true
+	if err := r.write(2, [][]byte{rec}); err == nil {
+		t.Fatal("write error expected")
+	}
+	w.broken = false
+	if err := r.write(2, [][]byte{rec}); err == nil {
+		t.Error("a roller with a partial record accepted a write")
+	}
+	if _, err := r.finish(); err == nil {
+		t.Error("a file with a partial record was finished")
+	}
+	if len(r.idx.Rounds) != 1 || r.idx.Last != 1 {
+		t.Errorf("index %+v", r.idx)
+	}
+}
@@ -0,0 +1,442 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package jetstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algonode/algostreamer/internal/utils"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	defaultURL     = nats.DefaultURL
+	defaultStream  = "ALGO"
+	defaultPrefix  = "algo"
+	defaultTimeout = 30
+)
+
+type JetStreamConfig struct {
+	URL      string `json:"url"`
+	Username string `json:"user"`
+	Password string `json:"pass"`
+	Token    string `json:"token"`
+	//Creds is a NATS credentials file with user JWT and nkey seed
+	Creds string `json:"creds"`
+	//Stream stores block and txn subjects, resume reads the last block from it
+	Stream string `json:"stream"`
+	//Create adds the stream with <prefix>.block.>, <prefix>.txn.>, <prefix>.checkpoint and <prefix>.tx.> subjects
+	//if it does not exist and adds missing block, txn and checkpoint subjects to an existing stream
+	Create bool `json:"create"`
+	//MaxAge of created stream messages in seconds, 0 keeps them forever
+	MaxAge   int `json:"maxAge"`
+	Replicas int `json:"replicas"`
+	//Prefix is the first subject token
+	Prefix string `json:"prefix"`
+	//Timeout is the number of seconds to wait for JetStream acks of a block
+	Timeout int              `json:"timeout"`
+	TLS     *utils.TLSConfig `json:"tls"`
+}
+
+type jsSink struct {
+	name string
+	cfg  *JetStreamConfig
+	nc   *nats.Conn
+	js   nats.JetStreamContext
+	//subjects stored by the stream, messages to other subjects would never be acked
+	subjects []string
+	//dropped remembers subjects already warned about
+	dropped map[string]bool
+}
+
+func init() {
+	sink.Register("jetstream", func() sink.Sink { return &jsSink{} })
+}
+
+//subjectToken replaces characters that separate subject tokens or act as wildcards
+var subjectToken = strings.NewReplacer(".", "_", " ", "_", "*", "_", ">", "_")
+
+func (s *jsSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &JetStreamConfig{}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, s.cfg); err != nil {
+			return fmt.Errorf("[NATS] invalid config: %s", err)
+		}
+	}
+	if s.cfg.URL == "" {
+		s.cfg.URL = defaultURL
+	}
+	if s.cfg.Stream == "" {
+		s.cfg.Stream = defaultStream
+	}
+	if s.cfg.Prefix == "" {
+		s.cfg.Prefix = defaultPrefix
+	}
+	if s.cfg.Timeout <= 0 {
+		s.cfg.Timeout = defaultTimeout
+	}
+
+	opts := []nats.Option{
+		nats.Name("algostreamer"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[WARN][NATS][%s] disconnected: %s\n", s.name, err)
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			fmt.Fprintf(os.Stderr, "[INFO][NATS][%s] reconnected to %s\n", s.name, nc.ConnectedUrl())
+		}),
+	}
+	if s.cfg.Username != "" {
+		opts = append(opts, nats.UserInfo(s.cfg.Username, s.cfg.Password))
+	}
+	if s.cfg.Token != "" {
+		opts = append(opts, nats.Token(s.cfg.Token))
+	}
+	if s.cfg.Creds != "" {
+		opts = append(opts, nats.UserCredentials(s.cfg.Creds))
+	}
+	//plain nats:// servers reject TLS, enable it only when asked for
+	if s.cfg.TLS != nil || strings.HasPrefix(s.cfg.URL, "tls://") {
+		tc, err := s.cfg.TLS.Load()
+		if err != nil {
+			return fmt.Errorf("[NATS] %s", err)
+		}
+		opts = append(opts, nats.Secure(tc))
+	}
+
+	nc, err := nats.Connect(s.cfg.URL, opts...)
+	if err != nil {
+		return fmt.Errorf("[NATS] %s", err)
+	}
+	js, err := nc.JetStream()
+	if err != nil {
+		nc.Close()
+		return fmt.Errorf("[NATS] %s", err)
+	}
+	s.nc, s.js = nc, js
+	if err := s.ensureStream(); err != nil {
+		nc.Close()
+		return err
+	}
+	return nil
+}
+
+//ensureStream creates the stream or checks it stores block, txn and checkpoint subjects
+func (s *jsSink) ensureStream() error {
+	required := []string{s.cfg.Prefix + ".block.>", s.cfg.Prefix + ".txn.>", s.checkpointSubject()}
+	info, err := s.js.StreamInfo(s.cfg.Stream)
+	if err == nil {
+		var missing []string
+		for _, r := range required {
+			if !covered(info.Config.Subjects, r) {
+				missing = append(missing, r)
+			}
+		}
+		s.subjects = info.Config.Subjects
+		if !covered(s.subjects, s.cfg.Prefix+".tx.>") {
+			fmt.Fprintf(os.Stderr, "[WARN][NATS][%s] stream %s does not store all of %s.tx.>, txn copies it does not store are skipped\n", s.name, s.cfg.Stream, s.cfg.Prefix)
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		if !s.cfg.Create {
+			return fmt.Errorf("[NATS] stream %s does not store %s", s.cfg.Stream, strings.Join(missing, ", "))
+		}
+		sc := info.Config
+		sc.Subjects = append(sc.Subjects, missing...)
+		if _, err := s.js.UpdateStream(&sc); err != nil {
+			return fmt.Errorf("[NATS] updating stream %s: %s", s.cfg.Stream, err)
+		}
+		s.subjects = sc.Subjects
+		fmt.Fprintf(os.Stderr, "[INFO][NATS][%s] stream %s now stores %s\n", s.name, s.cfg.Stream, strings.Join(missing, ", "))
+		return nil
+	}
+	if !errors.Is(err, nats.ErrStreamNotFound) || !s.cfg.Create {
+		return fmt.Errorf("[NATS] stream %s: %s", s.cfg.Stream, err)
+	}
+	s.subjects = append(required, s.cfg.Prefix+".tx.>")
+	_, err = s.js.AddStream(&nats.StreamConfig{
+		Name:     s.cfg.Stream,
+		Subjects: s.subjects,
+		Storage:  nats.FileStorage,
+		MaxAge:   time.Duration(s.cfg.MaxAge) * time.Second,
+		Replicas: s.cfg.Replicas,
+	})
+	if err != nil {
+		return fmt.Errorf("[NATS] creating stream %s: %s", s.cfg.Stream, err)
+	}
+	fmt.Fprintf(os.Stderr, "[INFO][NATS][%s] stream %s created\n", s.name, s.cfg.Stream)
+	return nil
+}
+
+//covered reports whether any of the stream subjects matches the subject,
+//wildcard subjects are covered only by the same or a wider wildcard
+func covered(subjects []string, subject string) bool {
+	for _, p := range subjects {
+		if subjectMatch(p, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+//subjectMatch matches a subject against a pattern with * and > wildcards
+func subjectMatch(pattern, subject string) bool {
+	pt := strings.Split(pattern, ".")
+	st := strings.Split(subject, ".")
+	for i, p := range pt {
+		if p == ">" {
+			return len(st) > i
+		}
+		if i >= len(st) {
+			return false
+		}
+		if p != "*" && p != st[i] {
+			return false
+		}
+		if p == "*" && st[i] == ">" {
+			return false
+		}
+	}
+	return len(pt) == len(st)
+}
+
+//stored checks that the stream keeps a rule topic, others are logged once and dropped
+//as JetStream would never ack them and the block would be retried forever
+func (s *jsSink) stored(subject string) bool {
+	if covered(s.subjects, subject) {
+		return true
+	}
+	if !s.dropped[subject] {
+		if s.dropped == nil {
+			s.dropped = make(map[string]bool)
+		}
+		s.dropped[subject] = true
+		fmt.Fprintf(os.Stderr, "[WARN][NATS][%s] stream %s does not store %s, messages to it are dropped\n", s.name, s.cfg.Stream, subject)
+	}
+	return false
+}
+
+func (s *jsSink) blockSubject(round uint64) string {
+	return fmt.Sprintf("%s.block.%d", s.cfg.Prefix, round)
+}
+
+func (s *jsSink) txnSubject(round uint64) string {
+	return fmt.Sprintf("%s.txn.%d", s.cfg.Prefix, round)
+}
+
+//checkpointSubject gets a message with the round number for every handled block, filtered out or not
+func (s *jsSink) checkpointSubject() string {
+	return s.cfg.Prefix + ".checkpoint"
+}
+
+//HandleBlock publishes txns first, then the block and the round checkpoint last,
+//so a stored checkpoint means all messages of the round are stored.
+//Every txn is stored once on <prefix>.txn.<round> with the <round>-<intra> message ID, so the server drops
+//duplicates when the block is retried or several instances publish the same round.
+//Copies on subscription key subjects get <round>-<intra>:<subject> IDs.
+func (s *jsSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	start := time.Now()
+	round := uint64(b.Block.Round)
+
+	acks := make([]nats.PubAckFuture, 0, len(b.Txns)*4)
+	publish := func(subject, id string, body []byte, hdr nats.Header) error {
+		m := nats.NewMsg(subject)
+		//MsgId sets a header, copies of a txn must not share it
+		for k, v := range hdr {
+			m.Header[k] = v
+		}
+		m.Data = body
+		var opts []nats.PubOpt
+		if id != "" {
+			opts = append(opts, nats.MsgId(id))
+		}
+		f, err := s.js.PublishMsgAsync(m, opts...)
+		if err != nil {
+			return fmt.Errorf("[NATS] publish %s: %s", subject, err)
+		}
+		acks = append(acks, f)
+		return nil
+	}
+
+	for _, tx := range b.Txns {
+		body, err := tx.Encode(tx.TxWrap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][NATS] %s\n", err)
+			continue
+		}
+		hdr := nats.Header{}
+		hdr.Set("Algo-Round", strconv.FormatUint(tx.Round, 10))
+		hdr.Set("Algo-Intra", strconv.Itoa(tx.Intra))
+		hdr.Set("Algo-Txid", tx.TxId)
+		//a txn routed to several subjects needs an ID per subject within the stream dedup window
+		if tx.Topic != "" {
+			if s.stored(tx.Topic) {
+				if err := publish(tx.Topic, tx.Key+":"+tx.Topic, body, hdr); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := publish(s.txnSubject(tx.Round), tx.Key, body, hdr); err != nil {
+			return err
+		}
+		for _, k := range sink.TopicKeys(tx.TxWrap) {
+			subject := fmt.Sprintf("%s.tx.%s.%s", s.cfg.Prefix, k.Kind, k.Value)
+			if !covered(s.subjects, subject) {
+				continue
+			}
+			if err := publish(subject, tx.Key+":"+subject, body, hdr); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.waitAcks(ctx, acks); err != nil {
+		return err
+	}
+
+	acks = acks[:0]
+	for i := range b.Msgs {
+		body, err := b.Msgs[i].Encode(b.Payload())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][NATS] %s\n", err)
+			continue
+		}
+		subject := b.Msgs[i].Topic
+		if subject == "" {
+			subject = s.blockSubject(round)
+		} else if !s.stored(subject) {
+			continue
+		}
+		hdr := nats.Header{}
+		hdr.Set("Algo-Round", strconv.FormatUint(round, 10))
+		if err := publish(subject, fmt.Sprintf("%d:%s", round, subject), body, hdr); err != nil {
+			return err
+		}
+	}
+	if err := s.waitAcks(ctx, acks); err != nil {
+		return err
+	}
+
+	acks = acks[:0]
+	hdr := nats.Header{}
+	hdr.Set("Algo-Round", strconv.FormatUint(round, 10))
+	subject := s.checkpointSubject()
+	if err := publish(subject, fmt.Sprintf("%d:%s", round, subject), []byte(strconv.FormatUint(round, 10)), hdr); err != nil {
+		return err
+	}
+	if err := s.waitAcks(ctx, acks); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "[INFO][NATS][%s] Block %d stored in %s (%d txn)\n", s.name, round, time.Since(start), len(b.Txns))
+	return nil
+}
+
+//waitAcks returns the first publish error, any error makes the runner retry the whole block
+func (s *jsSink) waitAcks(ctx context.Context, acks []nats.PubAckFuture) error {
+	timeout := time.After(time.Duration(s.cfg.Timeout) * time.Second)
+	for _, f := range acks {
+		select {
+		case <-f.Ok():
+		case err := <-f.Err():
+			return fmt.Errorf("[NATS] %s: %s", f.Msg().Subject, err)
+		case <-timeout:
+			return fmt.Errorf("[NATS] timeout waiting for JetStream acks")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+//HandleStatus uses core NATS, status updates are not stored in the stream
+func (s *jsSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	for i := range status.Msgs {
+		body, err := status.Msgs[i].Encode(status.Status)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][NATS] %s\n", err)
+			continue
+		}
+		subject := status.Msgs[i].Topic
+		if subject == "" {
+			subject = fmt.Sprintf("%s.status.%s", s.cfg.Prefix, subjectToken.Replace(status.NodeId))
+		}
+		if err := s.nc.Publish(subject, body); err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][NATS] %s\n", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *jsSink) Flush(ctx context.Context) error {
+	if s.nc == nil {
+		return nil
+	}
+	return s.nc.FlushWithContext(ctx)
+}
+
+func (s *jsSink) Close(ctx context.Context) error {
+	if s.nc == nil {
+		return nil
+	}
+	s.nc.Close()
+	return nil
+}
+
+//LastCommittedRound reads the last round checkpoint stored in the stream,
+//streams written before checkpoints were added resume from the last block subject
+func (s *jsSink) LastCommittedRound(ctx context.Context) (uint64, error) {
+	msg, err := s.js.GetLastMsg(s.cfg.Stream, s.checkpointSubject(), nats.Context(ctx))
+	if errors.Is(err, nats.ErrMsgNotFound) {
+		return s.lastBlockRound(ctx)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("[NATS] error getting last checkpoint %v", err)
+	}
+	r, err := strconv.ParseUint(string(msg.Data), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("[NATS] error getting last checkpoint - invalid round %q", msg.Data)
+	}
+	return r, nil
+}
+
+func (s *jsSink) lastBlockRound(ctx context.Context) (uint64, error) {
+	msg, err := s.js.GetLastMsg(s.cfg.Stream, s.cfg.Prefix+".block.>", nats.Context(ctx))
+	if errors.Is(err, nats.ErrMsgNotFound) {
+		return 0, sink.ErrNoCheckpoint
+	}
+	if err != nil {
+		return 0, fmt.Errorf("[NATS] error getting last block %v", err)
+	}
+	a := strings.Split(msg.Subject, ".")
+	r, err := strconv.ParseUint(a[len(a)-1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("[NATS] error getting last block - invalid subject %s", msg.Subject)
+	}
+	return r, nil
+}
@@ -0,0 +1,132 @@
+//go:build integration
+// +build integration
+
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package jetstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algorand/go-algorand-sdk/types"
+	"github.com/nats-io/nats.go"
+)
+
+func testBlock(round uint64) *sink.Block {
+	bw := &algod.BlockWrap{Block: &types.Block{BlockHeader: types.BlockHeader{Round: types.Round(round)}}}
+	b := &sink.Block{BlockWrap: bw, Msgs: []sink.Msg{{}}}
+	for i := 0; i < 2; i++ {
+		tx := types.Transaction{Type: types.PaymentTx}
+		tx.Sender, tx.Receiver = types.Address{1}, types.Address{2}
+		b.Txns = append(b.Txns, &sink.Tx{TxWrap: &algod.TxWrap{
+			TxId:  fmt.Sprintf("T%d-%d", round, i),
+			Txn:   &types.SignedTxnInBlock{SignedTxnWithAD: types.SignedTxnWithAD{SignedTxn: types.SignedTxn{Txn: tx}}},
+			Round: round,
+			Intra: i,
+			Key:   fmt.Sprintf("%d-%d", round, i),
+		}})
+	}
+	return b
+}
+
+//TestRetriedBlock stores a block published twice once, NATS_URL has to point to a JetStream enabled server
+func TestRetriedBlock(t *testing.T) {
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		t.Skip("NATS_URL is not set")
+	}
+	ctx := context.Background()
+	stream := fmt.Sprintf("TEST_%d", time.Now().UnixNano())
+	cfg, _ := json.Marshal(&JetStreamConfig{URL: url, Stream: stream, Create: true, Prefix: "test"})
+	s := &jsSink{}
+	if err := s.Init(ctx, "test", cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(ctx)
+	defer s.js.DeleteStream(stream)
+
+	if _, err := s.LastCommittedRound(ctx); !errors.Is(err, sink.ErrNoCheckpoint) {
+		t.Fatalf("empty stream: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.HandleBlock(ctx, testBlock(5)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	last, err := s.LastCommittedRound(ctx)
+	if err != nil || last != 5 {
+		t.Errorf("LastCommittedRound = %d, %v", last, err)
+	}
+	info, err := s.js.StreamInfo(stream, &nats.StreamInfoRequest{SubjectsFilter: "test.*.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for subject, want := range map[string]uint64{"test.block.5": 1, "test.txn.5": 2} {
+		if n := info.State.Subjects[subject]; n != want {
+			t.Errorf("%s has %d messages, want %d", subject, n, want)
+		}
+	}
+	//sender and receiver copies of both txns
+	info, err = s.js.StreamInfo(stream, &nats.StreamInfoRequest{SubjectsFilter: "test.tx.>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var copies uint64
+	for _, n := range info.State.Subjects {
+		copies += n
+	}
+	if copies != 4 {
+		t.Errorf("subscription key copies %v, want 4", info.State.Subjects)
+	}
+}
+
+//TestFilteredBlock advances the checkpoint for a block without block messages
+func TestFilteredBlock(t *testing.T) {
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		t.Skip("NATS_URL is not set")
+	}
+	ctx := context.Background()
+	stream := fmt.Sprintf("TEST_%d", time.Now().UnixNano())
+	cfg, _ := json.Marshal(&JetStreamConfig{URL: url, Stream: stream, Create: true, Prefix: "test"})
+	s := &jsSink{}
+	if err := s.Init(ctx, "test", cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(ctx)
+	defer s.js.DeleteStream(stream)
+
+	if err := s.HandleBlock(ctx, testBlock(5)); err != nil {
+		t.Fatal(err)
+	}
+	filtered := testBlock(6)
+	filtered.Msgs, filtered.Txns = nil, nil
+	if err := s.HandleBlock(ctx, filtered); err != nil {
+		t.Fatal(err)
+	}
+	last, err := s.LastCommittedRound(ctx)
+	if err != nil || last != 6 {
+		t.Errorf("LastCommittedRound = %d, %v", last, err)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package jetstream
+
+import "testing"
+
+func TestSubjectMatch(t *testing.T) {
+	tests := []struct {
+		pattern, subject string
+		want             bool
+	}{
+		{"algo.block.1", "algo.block.1", true},
+		{"algo.block.1", "algo.block.2", false},
+		{"algo.block.*", "algo.block.1", true},
+		{"algo.block.*", "algo.block.1.x", false},
+		{"algo.block.*", "algo.block", false},
+		{"algo.>", "algo.block.1", true},
+		{"algo.>", "algo", false},
+		{"algo.*.1", "algo.txn.1", true},
+		{"algo.txn.>", "algo.txn.>", true},
+		{"algo.txn.*", "algo.txn.>", false},
+		{"algo.>", "algo.tx.>", true},
+		{"other.>", "algo.block.1", false},
+	}
+	for _, tt := range tests {
+		if got := subjectMatch(tt.pattern, tt.subject); got != tt.want {
+			t.Errorf("subjectMatch(%s, %s) = %v, want %v", tt.pattern, tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestCovered(t *testing.T) {
+	subjects := []string{"algo.block.>", "algo.txn.>", "alerts.*"}
+	tests := []struct {
+		subject string
+		want    bool
+	}{
+		{"algo.block.>", true},
+		{"algo.txn.10", true},
+		{"algo.tx.>", false},
+		{"alerts.whale", true},
+		{"alerts.whale.big", false},
+	}
+	for _, tt := range tests {
+		if got := covered(subjects, tt.subject); got != tt.want {
+			t.Errorf("covered(%s) = %v, want %v", tt.subject, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algonode/algostreamer/internal/utils"
+	"github.com/algorand/go-algorand-sdk/types"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+const (
+	defaultBlocks     = "algo-blocks"
+	defaultTxns       = "algo-txns"
+	defaultStatus     = "algo-status"
+	defaultCheckpoint = "algo-checkpoint"
+	defaultPartitions = 12
+	resumeTimeout     = time.Second * 10
+	//checkpointKey is the same for every checkpoint record so compaction keeps the last one
+	checkpointKey = "round"
+)
+
+type SASLConfig struct {
+	//Mechanism is plain, scram-sha-256 or scram-sha-512
+	Mechanism string `json:"mechanism"`
+	Username  string `json:"user"`
+	Password  string `json:"pass"`
+}
+
+type KafkaConfig struct {
+	Brokers  []string `json:"brokers"`
+	ClientID string   `json:"clientid"`
+	//Blocks, Txns and Status are topic names
+	Blocks string `json:"blocks"`
+	Txns   string `json:"txns"`
+	Status string `json:"status"`
+	//Checkpoint topic gets the round of every handled block, resume reads the last one
+	Checkpoint string `json:"checkpoint"`
+	//TxKey selects the txn partition key - "sender" (default) or "app" which uses
+	//the app ID for application calls and the sender for all other txns
+	TxKey string `json:"txKey"`
+	//Create adds missing topics, blocks and checkpoint topics always get a single partition
+	Create     bool  `json:"create"`
+	Partitions int32 `json:"partitions"`
+	Replicas   int16 `json:"replicas"`
+	//Linger is the number of milliseconds to wait for more records before sending a batch
+	Linger int              `json:"linger"`
+	SASL   *SASLConfig      `json:"sasl"`
+	TLS    *utils.TLSConfig `json:"tls"`
+}
+
+type kafkaSink struct {
+	name string
+	cfg  *KafkaConfig
+	opts []kgo.Opt
+	cl   *kgo.Client
+}
+
+func init() {
+	sink.Register("kafka", func() sink.Sink { return &kafkaSink{} })
+}
+
+func (s *kafkaSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &KafkaConfig{}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, s.cfg); err != nil {
+			return fmt.Errorf("[KAFKA] invalid config: %s", err)
+		}
+	}
+	if len(s.cfg.Brokers) == 0 {
+		s.cfg.Brokers = []string{"localhost:9092"}
+	}
+	if s.cfg.ClientID == "" {
+		s.cfg.ClientID = "algostreamer"
+	}
+	if s.cfg.Blocks == "" {
+		s.cfg.Blocks = defaultBlocks
+	}
+	if s.cfg.Txns == "" {
+		s.cfg.Txns = defaultTxns
+	}
+	if s.cfg.Status == "" {
+		s.cfg.Status = defaultStatus
+	}
+	if s.cfg.Checkpoint == "" {
+		s.cfg.Checkpoint = defaultCheckpoint
+	}
+	if s.cfg.Partitions <= 0 {
+		s.cfg.Partitions = defaultPartitions
+	}
+	if s.cfg.Replicas <= 0 {
+		s.cfg.Replicas = 1
+	}
+	switch s.cfg.TxKey {
+	case "":
+		s.cfg.TxKey = "sender"
+	case "sender", "app":
+	default:
+		return fmt.Errorf("[KAFKA] txKey must be sender or app")
+	}
+
+	//the client is idempotent by default, records of a partition are written once and in order
+	s.opts = []kgo.Opt{
+		kgo.SeedBrokers(s.cfg.Brokers...),
+		kgo.ClientID(s.cfg.ClientID),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+		kgo.ProducerLinger(time.Duration(s.cfg.Linger) * time.Millisecond),
+	}
+	if s.cfg.TLS != nil {
+		tc, err := s.cfg.TLS.Load()
+		if err != nil {
+			return fmt.Errorf("[KAFKA] %s", err)
+		}
+		s.opts = append(s.opts, kgo.DialTLSConfig(tc))
+	}
+	if s.cfg.SASL != nil {
+		switch strings.ToLower(s.cfg.SASL.Mechanism) {
+		case "plain":
+			s.opts = append(s.opts, kgo.SASL(plain.Auth{User: s.cfg.SASL.Username, Pass: s.cfg.SASL.Password}.AsMechanism()))
+		case "scram-sha-256":
+			s.opts = append(s.opts, kgo.SASL(scram.Auth{User: s.cfg.SASL.Username, Pass: s.cfg.SASL.Password}.AsSha256Mechanism()))
+		case "scram-sha-512":
+			s.opts = append(s.opts, kgo.SASL(scram.Auth{User: s.cfg.SASL.Username, Pass: s.cfg.SASL.Password}.AsSha512Mechanism()))
+		default:
+			return fmt.Errorf("[KAFKA] unknown sasl mechanism %s", s.cfg.SASL.Mechanism)
+		}
+	}
+
+	cl, err := kgo.NewClient(s.opts...)
+	if err != nil {
+		return fmt.Errorf("[KAFKA] %s", err)
+	}
+	s.cl = cl
+	if s.cfg.Create {
+		if err := s.createTopics(ctx); err != nil {
+			cl.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *kafkaSink) createTopics(ctx context.Context) error {
+	adm := kadm.NewClient(s.cl)
+	//a single blocks partition keeps rounds in offset order
+	for topic, partitions := range map[string]int32{s.cfg.Blocks: 1, s.cfg.Txns: s.cfg.Partitions, s.cfg.Status: 1, s.cfg.Checkpoint: 1} {
+		var configs map[string]*string
+		if topic == s.cfg.Checkpoint {
+			//only the last checkpoint matters
+			compact := "compact"
+			configs = map[string]*string{"cleanup.policy": &compact}
+		}
+		res, err := adm.CreateTopics(ctx, partitions, s.cfg.Replicas, configs, topic)
+		if err != nil {
+			return fmt.Errorf("[KAFKA] creating topic %s: %s", topic, err)
+		}
+		for _, r := range res {
+			if r.Err != nil && !errors.Is(r.Err, kerr.TopicAlreadyExists) {
+				return fmt.Errorf("[KAFKA] creating topic %s: %s", topic, r.Err)
+			}
+			if r.Err == nil {
+				fmt.Fprintf(os.Stderr, "[INFO][KAFKA][%s] topic %s created with %d partitions\n", s.name, topic, partitions)
+			}
+		}
+	}
+	return nil
+}
+
+//txKey is the partition key so txns of one sender or one app stay in order
+func (s *kafkaSink) txKey(tx *types.Transaction) []byte {
+	if s.cfg.TxKey == "app" && tx.Type == types.ApplicationCallTx && tx.ApplicationID != 0 {
+		return []byte(strconv.FormatUint(uint64(tx.ApplicationID), 10))
+	}
+	return []byte(tx.Sender.String())
+}
+
+func header(k, v string) kgo.RecordHeader {
+	return kgo.RecordHeader{Key: k, Value: []byte(v)}
+}
+
+//HandleBlock produces txns first, then the block and the checkpoint record last,
+//so the last record of the checkpoint topic marks a fully written round.
+//The checkpoint is produced for filtered out blocks as well.
+func (s *kafkaSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	start := time.Now()
+	round := uint64(b.Block.Round)
+	rs := strconv.FormatUint(round, 10)
+
+	txns := make([]*kgo.Record, 0, len(b.Txns))
+	for _, tx := range b.Txns {
+		body, err := tx.Encode(tx.TxWrap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][KAFKA] %s\n", err)
+			continue
+		}
+		topic := tx.Topic
+		if topic == "" {
+			topic = s.cfg.Txns
+		}
+		txns = append(txns, &kgo.Record{
+			Topic: topic,
+			Key:   s.txKey(&tx.Txn.Txn),
+			Value: body,
+			Headers: []kgo.RecordHeader{
+				header("round", rs),
+				header("intra", strconv.Itoa(tx.Intra)),
+				header("txid", tx.TxId),
+				header("id", tx.Key),
+			},
+		})
+	}
+	if err := s.cl.ProduceSync(ctx, txns...).FirstErr(); err != nil {
+		return fmt.Errorf("[KAFKA] produce txns: %s", err)
+	}
+
+	blocks := make([]*kgo.Record, 0, len(b.Msgs))
+	for i := range b.Msgs {
+		body, err := b.Msgs[i].Encode(b.Payload())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][KAFKA] %s\n", err)
+			continue
+		}
+		topic := b.Msgs[i].Topic
+		if topic == "" {
+			topic = s.cfg.Blocks
+		}
+		blocks = append(blocks, &kgo.Record{
+			Topic:   topic,
+			Key:     []byte(rs),
+			Value:   body,
+			Headers: []kgo.RecordHeader{header("round", rs)},
+		})
+	}
+	if err := s.cl.ProduceSync(ctx, blocks...).FirstErr(); err != nil {
+		return fmt.Errorf("[KAFKA] produce block: %s", err)
+	}
+	if err := s.cl.ProduceSync(ctx, &kgo.Record{
+		Topic: s.cfg.Checkpoint,
+		Key:   []byte(checkpointKey),
+		Value: []byte(rs),
+	}).FirstErr(); err != nil {
+		return fmt.Errorf("[KAFKA] produce checkpoint: %s", err)
+	}
+	fmt.Fprintf(os.Stderr, "[INFO][KAFKA][%s] Block %d produced in %s (%d txn)\n", s.name, round, time.Since(start), len(txns))
+	return nil
+}
+
+func (s *kafkaSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	for i := range status.Msgs {
+		body, err := status.Msgs[i].Encode(status.Status)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][KAFKA] %s\n", err)
+			continue
+		}
+		topic := status.Msgs[i].Topic
+		if topic == "" {
+			topic = s.cfg.Status
+		}
+		//status updates are best effort, do not wait for acks
+		s.cl.Produce(ctx, &kgo.Record{Topic: topic, Key: []byte(status.NodeId), Value: body}, func(r *kgo.Record, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[!ERR][KAFKA] status: %s\n", err)
+			}
+		})
+	}
+	return nil
+}
+
+func (s *kafkaSink) Flush(ctx context.Context) error {
+	if s.cl == nil {
+		return nil
+	}
+	return s.cl.Flush(ctx)
+}
+
+func (s *kafkaSink) Close(ctx context.Context) error {
+	if s.cl == nil {
+		return nil
+	}
+	s.cl.Close()
+	return nil
+}
+
+//LastCommittedRound reads the round of the last checkpoint record,
+//topics written before checkpoints were added resume from the last record of the blocks topic
+func (s *kafkaSink) LastCommittedRound(ctx context.Context) (uint64, error) {
+	round, err := s.lastRound(ctx, s.cfg.Checkpoint, func(r *kgo.Record) []byte { return r.Value })
+	if errors.Is(err, sink.ErrNoCheckpoint) {
+		return s.lastRound(ctx, s.cfg.Blocks, func(r *kgo.Record) []byte { return r.Key })
+	}
+	return round, err
+}
+
+//lastRound reads the highest round of the last records in every partition of a topic
+func (s *kafkaSink) lastRound(ctx context.Context, topic string, field func(*kgo.Record) []byte) (uint64, error) {
+	ends, err := kadm.NewClient(s.cl).ListEndOffsets(ctx, topic)
+	if err != nil {
+		return 0, fmt.Errorf("[KAFKA] error getting end offsets %v", err)
+	}
+	last := make(map[int32]kgo.Offset)
+	ends.Each(func(o kadm.ListedOffset) {
+		if o.Err == nil && o.Offset > 0 {
+			last[o.Partition] = kgo.NewOffset().At(o.Offset - 1)
+		}
+	})
+	if len(last) == 0 {
+		return 0, sink.ErrNoCheckpoint
+	}
+
+	cl, err := kgo.NewClient(append(s.opts, kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{topic: last}))...)
+	if err != nil {
+		return 0, fmt.Errorf("[KAFKA] %s", err)
+	}
+	defer cl.Close()
+	ctx, cancel := context.WithTimeout(ctx, resumeTimeout)
+	defer cancel()
+
+	var (
+		max  uint64
+		seen = make(map[int32]bool)
+	)
+	for len(seen) < len(last) {
+		fetches := cl.PollFetches(ctx)
+		if ctx.Err() != nil {
+			return 0, fmt.Errorf("[KAFKA] error reading last round of %s %v", topic, ctx.Err())
+		}
+		for _, fe := range fetches.Errors() {
+			return 0, fmt.Errorf("[KAFKA] error reading last round of %s %v", topic, fe.Err)
+		}
+		fetches.EachRecord(func(r *kgo.Record) {
+			seen[r.Partition] = true
+			round, err := strconv.ParseUint(string(field(r)), 10, 64)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[WARN][KAFKA] invalid round at %s/%d@%d\n", r.Topic, r.Partition, r.Offset)
+				return
+			}
+			if round > max {
+				max = round
+			}
+		})
+	}
+	return max, nil
+}
@@ -0,0 +1,196 @@
+//go:build integration
+// +build integration
+
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algorand/go-algorand-sdk/types"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+var (
+	alice = types.Address{1}
+	bob   = types.Address{2}
+)
+
+func testBlock(round uint64) *sink.Block {
+	bw := &algod.BlockWrap{Block: &types.Block{BlockHeader: types.BlockHeader{Round: types.Round(round)}}}
+	b := &sink.Block{BlockWrap: bw, Msgs: []sink.Msg{{}}}
+	pay := types.Transaction{Type: types.PaymentTx}
+	pay.Sender, pay.Receiver = alice, bob
+	appl := types.Transaction{Type: types.ApplicationCallTx}
+	appl.Sender, appl.ApplicationID = bob, 10
+	for i, tx := range []types.Transaction{pay, appl} {
+		b.Txns = append(b.Txns, &sink.Tx{TxWrap: &algod.TxWrap{
+			TxId:  fmt.Sprintf("T%d-%d", round, i),
+			Txn:   &types.SignedTxnInBlock{SignedTxnWithAD: types.SignedTxnWithAD{SignedTxn: types.SignedTxn{Txn: tx}}},
+			Round: round,
+			Intra: i,
+			Key:   fmt.Sprintf("%d-%d", round, i),
+		}})
+	}
+	return b
+}
+
+//testSink creates topics with a unique prefix, KAFKA_BROKERS is a comma separated broker list
+func testSink(t *testing.T, txKey string) *kafkaSink {
+	brokers := os.Getenv("KAFKA_BROKERS")
+	if brokers == "" {
+		t.Skip("KAFKA_BROKERS is not set")
+	}
+	prefix := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	cfg, _ := json.Marshal(&KafkaConfig{
+		Brokers:    strings.Split(brokers, ","),
+		Blocks:     prefix + "-blocks",
+		Txns:       prefix + "-txns",
+		Status:     prefix + "-status",
+		Checkpoint: prefix + "-checkpoint",
+		TxKey:      txKey,
+		Create:     true,
+		Partitions: 3,
+	})
+	s := &kafkaSink{}
+	if err := s.Init(context.Background(), "test", cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		kadm.NewClient(s.cl).DeleteTopics(context.Background(), s.cfg.Blocks, s.cfg.Txns, s.cfg.Status, s.cfg.Checkpoint)
+		s.Close(context.Background())
+	})
+	return s
+}
+
+//consume reads n records of a topic from the start
+func consume(t *testing.T, s *kafkaSink, topic string, n int) []*kgo.Record {
+	cl, err := kgo.NewClient(append(s.opts, kgo.ConsumeTopics(topic), kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	var records []*kgo.Record
+	for len(records) < n {
+		fetches := cl.PollFetches(ctx)
+		if ctx.Err() != nil {
+			t.Fatalf("%s: got %d records, want %d", topic, len(records), n)
+		}
+		records = append(records, fetches.Records()...)
+	}
+	return records
+}
+
+func headers(r *kgo.Record) map[string]string {
+	h := make(map[string]string, len(r.Headers))
+	for _, rh := range r.Headers {
+		h[rh.Key] = string(rh.Value)
+	}
+	return h
+}
+
+func TestPartitions(t *testing.T) {
+	s := testSink(t, "")
+	topics, err := kadm.NewClient(s.cl).ListTopics(context.Background(), s.cfg.Blocks, s.cfg.Txns, s.cfg.Checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for topic, want := range map[string]int{s.cfg.Blocks: 1, s.cfg.Txns: 3, s.cfg.Checkpoint: 1} {
+		if n := len(topics[topic].Partitions); n != want {
+			t.Errorf("%s has %d partitions, want %d", topic, n, want)
+		}
+	}
+}
+
+func TestRecords(t *testing.T) {
+	tests := []struct {
+		txKey string
+		keys  []string
+	}{
+		{"sender", []string{alice.String(), bob.String()}},
+		{"app", []string{alice.String(), "10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.txKey, func(t *testing.T) {
+			s := testSink(t, tt.txKey)
+			if err := s.HandleBlock(context.Background(), testBlock(5)); err != nil {
+				t.Fatal(err)
+			}
+			txns := consume(t, s, s.cfg.Txns, 2)
+			for _, r := range txns {
+				h := headers(r)
+				intra := 0
+				if h["intra"] == "1" {
+					intra = 1
+				}
+				want := map[string]string{"round": "5", "intra": h["intra"], "txid": fmt.Sprintf("T5-%d", intra), "id": fmt.Sprintf("5-%d", intra)}
+				for k, v := range want {
+					if h[k] != v {
+						t.Errorf("header %s = %q, want %q", k, h[k], v)
+					}
+				}
+				if string(r.Key) != tt.keys[intra] {
+					t.Errorf("txn %d key %s, want %s", intra, r.Key, tt.keys[intra])
+				}
+			}
+			blocks := consume(t, s, s.cfg.Blocks, 1)
+			if string(blocks[0].Key) != "5" || headers(blocks[0])["round"] != "5" {
+				t.Errorf("block key %s, headers %v", blocks[0].Key, headers(blocks[0]))
+			}
+		})
+	}
+}
+
+func TestResume(t *testing.T) {
+	s := testSink(t, "")
+	ctx := context.Background()
+	if _, err := s.LastCommittedRound(ctx); !errors.Is(err, sink.ErrNoCheckpoint) {
+		t.Fatalf("empty topics: %v", err)
+	}
+
+	//topics written before checkpoints resume from the blocks topic
+	if err := s.cl.ProduceSync(ctx, &kgo.Record{Topic: s.cfg.Blocks, Key: []byte("4"), Value: []byte("{}")}).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+	if last, err := s.LastCommittedRound(ctx); err != nil || last != 4 {
+		t.Errorf("LastCommittedRound = %d, %v, want 4 from blocks", last, err)
+	}
+
+	if err := s.HandleBlock(ctx, testBlock(5)); err != nil {
+		t.Fatal(err)
+	}
+	filtered := testBlock(6)
+	filtered.Msgs, filtered.Txns = nil, nil
+	if err := s.HandleBlock(ctx, filtered); err != nil {
+		t.Fatal(err)
+	}
+	if last, err := s.LastCommittedRound(ctx); err != nil || last != 6 {
+		t.Errorf("LastCommittedRound = %d, %v, want 6", last, err)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	paho5 "github.com/eclipse/paho.golang/paho"
+	paho3 "github.com/eclipse/paho.mqtt.golang"
+)
+
+//v3Client talks MQTT v3.1.1, publishes are pipelined and acks collected per block
+type v3Client struct {
+	c   paho3.Client
+	qos byte
+}
+
+func newV3Client(cfg *MqttConfig, tc *tls.Config) (*v3Client, error) {
+	opts := paho3.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetTLSConfig(tc).
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOrderMatters(false).
+		SetConnectionLostHandler(func(c paho3.Client, err error) {
+			fmt.Fprintf(os.Stderr, "[!ERR][MQTT] connection lost: %s\n", err)
+		})
+	c := paho3.NewClient(opts)
+	t := c.Connect()
+	//with ConnectRetry the client keeps trying in the background
+	if t.WaitTimeout(connectTimeout) && t.Error() != nil {
+		return nil, t.Error()
+	}
+	return &v3Client{c: c, qos: cfg.QoS}, nil
+}
+
+func (c *v3Client) publishAll(ctx context.Context, msgs []message) error {
+	tokens := make([]paho3.Token, 0, len(msgs))
+	for i := range msgs {
+		tokens = append(tokens, c.c.Publish(msgs[i].topic, c.qos, msgs[i].retain, msgs[i].payload))
+	}
+	for _, t := range tokens {
+		select {
+		case <-t.Done():
+			if err := t.Error(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (c *v3Client) close(ctx context.Context) error {
+	c.c.Disconnect(250)
+	return nil
+}
+
+//v5Client talks MQTT v5, autopaho handles reconnects
+type v5Client struct {
+	cm  *autopaho.ConnectionManager
+	qos byte
+}
+
+func newV5Client(ctx context.Context, cfg *MqttConfig, tc *tls.Config) (*v5Client, error) {
+	u, err := url.Parse(cfg.Broker)
+	if err != nil {
+		return nil, err
+	}
+	acfg := autopaho.ClientConfig{
+		BrokerUrls:     []*url.URL{u},
+		TlsCfg:         tc,
+		KeepAlive:      30,
+		ConnectTimeout: connectTimeout,
+		OnConnectError: func(err error) {
+			fmt.Fprintf(os.Stderr, "[!ERR][MQTT] %s\n", err)
+		},
+		ClientConfig: paho5.ClientConfig{
+			ClientID: cfg.ClientID,
+		},
+	}
+	if cfg.Username != "" {
+		acfg.SetUsernamePassword(cfg.Username, []byte(cfg.Password))
+	}
+	//the connection outlives Init so it is still up when sinks get flushed on shutdown
+	cm, err := autopaho.NewConnection(context.Background(), acfg)
+	if err != nil {
+		return nil, err
+	}
+	cctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	if err := cm.AwaitConnection(cctx); err != nil {
+		fmt.Fprintf(os.Stderr, "[WARN][MQTT] broker %s not connected yet: %s\n", cfg.Broker, err)
+	}
+	return &v5Client{cm: cm, qos: cfg.QoS}, nil
+}
+
+//publishAll keeps the order of messages on each topic.
+//autopaho Publish waits for the ack, so topics are published concurrently to keep throughput.
+func (c *v5Client) publishAll(ctx context.Context, msgs []message) error {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		ferr   error
+		topics []string
+	)
+	byTopic := make(map[string][]*message)
+	for i := range msgs {
+		t := msgs[i].topic
+		if _, ok := byTopic[t]; !ok {
+			topics = append(topics, t)
+		}
+		byTopic[t] = append(byTopic[t], &msgs[i])
+	}
+	sem := make(chan struct{}, maxInflight)
+	for _, t := range topics {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(ms []*message) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			for _, m := range ms {
+				_, err := c.cm.Publish(ctx, &paho5.Publish{
+					Topic:   m.topic,
+					QoS:     c.qos,
+					Retain:  m.retain,
+					Payload: m.payload,
+				})
+				if err != nil {
+					mu.Lock()
+					if ferr == nil {
+						ferr = err
+					}
+					mu.Unlock()
+					//later messages of the topic would overtake the failed one
+					return
+				}
+			}
+		}(byTopic[t])
+	}
+	wg.Wait()
+	return ferr
+}
+
+func (c *v5Client) close(ctx context.Context) error {
+	return c.cm.Disconnect(ctx)
+}
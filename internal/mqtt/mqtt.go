@@ -0,0 +1,193 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package mqtt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algonode/algostreamer/internal/utils"
+)
+
+const (
+	defaultPrefix  = "algo"
+	connectTimeout = time.Second * 10
+	//maxInflight caps topics published at once by the v5 client
+	maxInflight = 64
+)
+
+type MqttConfig struct {
+	//Broker is the broker URL - mqtt://host:1883 or mqtts://host:8883
+	Broker string `json:"broker"`
+	//Version is the protocol version - 4 for v3.1.1 (default) or 5
+	Version  int    `json:"version"`
+	ClientID string `json:"clientid"`
+	Username string `json:"user"`
+	Password string `json:"pass"`
+	QoS      byte   `json:"qos"`
+	//Retain keeps the last block and node status on the broker for new subscribers
+	Retain bool `json:"retain"`
+	//Prefix is the first topic level, "algo" by default
+	Prefix string           `json:"prefix"`
+	TLS    *utils.TLSConfig `json:"tls"`
+}
+
+type message struct {
+	topic   string
+	payload []byte
+	retain  bool
+}
+
+//publisher hides the differences between v3.1.1 and v5 clients
+type publisher interface {
+	//publishAll returns when all messages are acknowledged according to QoS
+	publishAll(ctx context.Context, msgs []message) error
+	close(ctx context.Context) error
+}
+
+type mqttSink struct {
+	sink.NoCheckpoint
+	name string
+	cfg  *MqttConfig
+	pub  publisher
+}
+
+func init() {
+	sink.Register("mqtt", func() sink.Sink { return &mqttSink{} })
+}
+
+func (s *mqttSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &MqttConfig{}
+	if len(cfg) == 0 {
+		return fmt.Errorf("[MQTT] mqtt config is missing")
+	}
+	if err := json.Unmarshal(cfg, s.cfg); err != nil {
+		return fmt.Errorf("[MQTT] invalid config: %s", err)
+	}
+	if s.cfg.Broker == "" {
+		return fmt.Errorf("[MQTT] broker is missing")
+	}
+	if s.cfg.QoS > 2 {
+		return fmt.Errorf("[MQTT] invalid qos %d", s.cfg.QoS)
+	}
+	if s.cfg.Prefix == "" {
+		s.cfg.Prefix = defaultPrefix
+	}
+	if s.cfg.ClientID == "" {
+		s.cfg.ClientID = randomClientID()
+	}
+	tc, err := s.cfg.TLS.Load()
+	if err != nil {
+		return fmt.Errorf("[MQTT] %s", err)
+	}
+
+	switch s.cfg.Version {
+	case 0, 4:
+		s.pub, err = newV3Client(s.cfg, tc)
+	case 5:
+		s.pub, err = newV5Client(ctx, s.cfg, tc)
+	default:
+		err = fmt.Errorf("unsupported protocol version %d", s.cfg.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("[MQTT] %s", err)
+	}
+	return nil
+}
+
+//HandleBlock publishes the block to <prefix>/block and every txn
+//to <prefix>/<kind>/<key>/<txid> for each of its subscription keys
+//so that wildcards like algo/acc/<ADDR>/# work.
+func (s *mqttSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	start := time.Now()
+	msgs := make([]message, 0, len(b.Msgs)+len(b.Txns)*4)
+	for i := range b.Msgs {
+		payload, err := b.Msgs[i].Encode(b.Payload())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][MQTT] %s\n", err)
+			continue
+		}
+		if b.Msgs[i].Topic != "" {
+			msgs = append(msgs, message{topic: b.Msgs[i].Topic, payload: payload})
+			continue
+		}
+		msgs = append(msgs, message{topic: s.cfg.Prefix + "/block", payload: payload, retain: s.cfg.Retain})
+	}
+	for _, tx := range b.Txns {
+		payload, err := tx.Encode(tx.TxWrap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][MQTT] %s\n", err)
+			continue
+		}
+		if tx.Topic != "" {
+			msgs = append(msgs, message{topic: tx.Topic, payload: payload})
+			continue
+		}
+		for _, k := range sink.TopicKeys(tx.TxWrap) {
+			msgs = append(msgs, message{
+				topic:   fmt.Sprintf("%s/%s/%s/%s", s.cfg.Prefix, k.Kind, k.Value, tx.TxId),
+				payload: payload,
+			})
+		}
+	}
+	if err := s.pub.publishAll(ctx, msgs); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "[INFO][MQTT][%s] Block %d published in %s (%d txn, %d msg)\n", s.name, uint64(b.Block.Round), time.Since(start), len(b.Txns), len(msgs))
+	return nil
+}
+
+func (s *mqttSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	msgs := make([]message, 0, len(status.Msgs))
+	for i := range status.Msgs {
+		payload, err := status.Msgs[i].Encode(status.Status)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][MQTT] %s\n", err)
+			continue
+		}
+		topic := status.Msgs[i].Topic
+		if topic == "" {
+			topic = s.cfg.Prefix + "/status/" + status.NodeId
+		}
+		msgs = append(msgs, message{topic: topic, payload: payload, retain: s.cfg.Retain})
+	}
+	if err := s.pub.publishAll(ctx, msgs); err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][MQTT] %s\n", err)
+		return err
+	}
+	return nil
+}
+
+func (s *mqttSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *mqttSink) Close(ctx context.Context) error {
+	return s.pub.close(ctx)
+}
+
+func randomClientID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "algostreamer-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,106 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package mqtt
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+//recorder keeps published messages instead of sending them
+type recorder struct {
+	msgs []message
+}
+
+func (r *recorder) publishAll(ctx context.Context, msgs []message) error {
+	r.msgs = append(r.msgs, msgs...)
+	return nil
+}
+
+func (r *recorder) close(ctx context.Context) error {
+	return nil
+}
+
+func txn(txid string, tx types.Transaction, topic string) *sink.Tx {
+	return &sink.Tx{
+		TxWrap: &algod.TxWrap{TxId: txid, Txn: &types.SignedTxnInBlock{SignedTxnWithAD: types.SignedTxnWithAD{SignedTxn: types.SignedTxn{Txn: tx}}}},
+		Msg:    sink.Msg{Topic: topic},
+	}
+}
+
+func TestTopics(t *testing.T) {
+	alice, bob := types.Address{1}, types.Address{2}
+	axfer := types.Transaction{Type: types.AssetTransferTx}
+	axfer.Sender, axfer.AssetReceiver, axfer.XferAsset = alice, bob, 31566704
+	axfer.Note = []byte{0xfb, 0xff}
+	bw := &algod.BlockWrap{Block: &types.Block{BlockHeader: types.BlockHeader{Round: 10}}}
+
+	type pub struct {
+		topic  string
+		retain bool
+	}
+	tests := []struct {
+		name string
+		b    *sink.Block
+		want []pub
+	}{
+		{
+			name: "block is retained",
+			b:    &sink.Block{BlockWrap: bw, Msgs: []sink.Msg{{}}},
+			want: []pub{{"algo/block", true}},
+		},
+		{
+			name: "filtered block",
+			b:    &sink.Block{BlockWrap: bw},
+		},
+		{
+			name: "rule topic",
+			b:    &sink.Block{BlockWrap: bw, Msgs: []sink.Msg{{Topic: "alerts/block"}}, Txns: []*sink.Tx{txn("TX1", axfer, "alerts/whale")}},
+			want: []pub{{"alerts/block", false}, {"alerts/whale", false}},
+		},
+		{
+			name: "txn per key",
+			b:    &sink.Block{BlockWrap: bw, Txns: []*sink.Tx{txn("TX1", axfer, "")}},
+			want: []pub{
+				{"algo/acc/" + alice.String() + "/TX1", false},
+				{"algo/acc/" + bob.String() + "/TX1", false},
+				{"algo/asa/31566704/TX1", false},
+				{"algo/note/-_8/TX1", false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{}
+			s := &mqttSink{cfg: &MqttConfig{Prefix: "algo", Retain: true}, pub: rec}
+			if err := s.HandleBlock(context.Background(), tt.b); err != nil {
+				t.Fatal(err)
+			}
+			var got []pub
+			for _, m := range rec.msgs {
+				got = append(got, pub{m.topic, m.retain})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
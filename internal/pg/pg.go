@@ -0,0 +1,203 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algonode/algostreamer/internal/utils"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type PgConfig struct {
+	//DSN is a postgres:// URL or a key=value connection string
+	DSN string `json:"dsn"`
+	//Raw stores the msgpack block in blocks.raw
+	Raw bool `json:"raw"`
+	//Checkpoint names the checkpoint row, defaults to the sink name
+	Checkpoint string `json:"checkpoint"`
+}
+
+type pgSink struct {
+	name string
+	cfg  *PgConfig
+	pool *pgxpool.Pool
+}
+
+func init() {
+	sink.Register("postgres", func() sink.Sink { return &pgSink{} })
+}
+
+func (s *pgSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &PgConfig{}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, s.cfg); err != nil {
+			return fmt.Errorf("[PG] invalid config: %s", err)
+		}
+	}
+	if s.cfg.DSN == "" {
+		return fmt.Errorf("[PG] dsn is missing")
+	}
+	if s.cfg.Checkpoint == "" {
+		s.cfg.Checkpoint = name
+	}
+	pool, err := pgxpool.Connect(ctx, s.cfg.DSN)
+	if err != nil {
+		return fmt.Errorf("[PG] %s", err)
+	}
+	if _, err := pool.Exec(ctx, schema); err != nil {
+		pool.Close()
+		return fmt.Errorf("[PG] creating schema: %s", err)
+	}
+	s.pool = pool
+	return nil
+}
+
+//HandleBlock writes the block, its txns and participation rows together with
+//the checkpoint in a single transaction. Rounds at or below the checkpoint are skipped
+//so a retried or replayed block is stored exactly once.
+func (s *pgSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	start := time.Now()
+	round := uint64(b.Block.Round)
+	err := s.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		//lock the checkpoint row so concurrent streamers serialize on it
+		if _, err := tx.Exec(ctx, `INSERT INTO checkpoint (name, round, updated) VALUES ($1, -1, now()) ON CONFLICT (name) DO NOTHING`, s.cfg.Checkpoint); err != nil {
+			return err
+		}
+		var cp int64
+		if err := tx.QueryRow(ctx, `SELECT round FROM checkpoint WHERE name = $1 FOR UPDATE`, s.cfg.Checkpoint).Scan(&cp); err != nil {
+			return err
+		}
+		if int64(round) <= cp {
+			return nil
+		}
+
+		batch := &pgx.Batch{}
+		if len(b.Msgs) > 0 {
+			header, err := utils.EncodeJson(b.Block.BlockHeader)
+			if err != nil {
+				return err
+			}
+			var raw []byte
+			if s.cfg.Raw {
+				raw = b.BlockRaw
+			}
+			batch.Queue(`INSERT INTO blocks (round, ts, genesis_id, txn_count, header, raw) VALUES ($1, $2, $3, $4, $5, $6)
+				ON CONFLICT (round) DO NOTHING`,
+				int64(round), time.Unix(b.Block.TimeStamp, 0).UTC(), b.Block.GenesisID, len(b.Block.Payset), string(header), raw)
+		}
+
+		txns := 0
+		for _, t := range b.Txns {
+			body, err := t.Encode(t.TxWrap)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[!ERR][PG] %s\n", err)
+				continue
+			}
+			r := sink.Row(b.BlockWrap, t.TxWrap)
+			batch.Queue(`INSERT INTO txns (round, intra, txid, type, sender, receiver, amount, asset, app, fee, grp, note, ts, txn)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+				ON CONFLICT (round, intra) DO NOTHING`,
+				int64(r.Round), r.Intra, r.TxId, r.Type, r.Sender, nullStr(r.Receiver), numeric(r.Amount),
+				nullInt(r.Asset), nullInt(r.App), int64(r.Fee), nullStr(r.Group), r.Note,
+				time.Unix(r.Ts, 0).UTC(), string(body))
+			for _, topic := range t.Topics() {
+				a := strings.SplitN(topic, ":", 2)
+				if len(a) != 2 || a[1] == "" {
+					continue
+				}
+				batch.Queue(`INSERT INTO txn_participation (kind, key, round, intra) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`,
+					strings.ToLower(a[0]), a[1], int64(r.Round), r.Intra)
+			}
+			txns++
+		}
+		batch.Queue(`UPDATE checkpoint SET round = $2, updated = now() WHERE name = $1`, s.cfg.Checkpoint, int64(round))
+
+		br := tx.SendBatch(ctx, batch)
+		for i := 0; i < batch.Len(); i++ {
+			if _, err := br.Exec(); err != nil {
+				br.Close()
+				return err
+			}
+		}
+		if err := br.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "[INFO][PG][%s] Block %d committed in %s (%d txn)\n", s.name, round, time.Since(start), txns)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("[PG] block %d: %s", round, err)
+	}
+	return nil
+}
+
+func nullStr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func nullInt(v uint64) *int64 {
+	if v == 0 {
+		return nil
+	}
+	i := int64(v)
+	return &i
+}
+
+//numeric passes uint64 amounts as text, they do not fit BIGINT
+func numeric(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+func (s *pgSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	return nil
+}
+
+func (s *pgSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *pgSink) Close(ctx context.Context) error {
+	if s.pool != nil {
+		s.pool.Close()
+	}
+	return nil
+}
+
+//LastCommittedRound reads the checkpoint row
+func (s *pgSink) LastCommittedRound(ctx context.Context) (uint64, error) {
+	var cp int64
+	err := s.pool.QueryRow(ctx, `SELECT round FROM checkpoint WHERE name = $1`, s.cfg.Checkpoint).Scan(&cp)
+	if err == pgx.ErrNoRows || (err == nil && cp < 0) {
+		return 0, sink.ErrNoCheckpoint
+	}
+	if err != nil {
+		return 0, fmt.Errorf("[PG] error getting checkpoint %v", err)
+	}
+	return uint64(cp), nil
+}
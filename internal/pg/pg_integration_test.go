@@ -0,0 +1,172 @@
+//go:build integration
+// +build integration
+
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algorand/go-algorand-sdk/types"
+	"github.com/jackc/pgx/v4"
+)
+
+//testSink creates the tables in a new schema of PG_DSN and drops it after the test
+func testSink(t *testing.T) *pgSink {
+	dsn := os.Getenv("PG_DSN")
+	if dsn == "" {
+		t.Skip("PG_DSN is not set")
+	}
+	ctx := context.Background()
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+	if _, err := conn.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn, err := pgx.Connect(ctx, dsn)
+		if err != nil {
+			return
+		}
+		defer conn.Close(ctx)
+		conn.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	switch {
+	case !strings.Contains(dsn, "://"):
+		dsn += " search_path=" + schema
+	case strings.Contains(dsn, "?"):
+		dsn += "&search_path=" + schema
+	default:
+		dsn += "?search_path=" + schema
+	}
+	cfg, _ := json.Marshal(&PgConfig{DSN: dsn})
+	s := &pgSink{}
+	if err := s.Init(ctx, "test", cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close(ctx) })
+	return s
+}
+
+func testBlock(round uint64, txids ...string) *sink.Block {
+	bw := &algod.BlockWrap{Block: &types.Block{BlockHeader: types.BlockHeader{Round: types.Round(round), TimeStamp: 1660000000, GenesisID: "testnet-v1.0"}}}
+	b := &sink.Block{BlockWrap: bw, Msgs: []sink.Msg{{}}}
+	for i, txid := range txids {
+		tx := types.Transaction{Type: types.PaymentTx}
+		tx.Sender, tx.Receiver, tx.Amount = types.Address{1}, types.Address{2}, 5
+		b.Txns = append(b.Txns, &sink.Tx{TxWrap: &algod.TxWrap{
+			TxId:  txid,
+			Txn:   &types.SignedTxnInBlock{SignedTxnWithAD: types.SignedTxnWithAD{SignedTxn: types.SignedTxn{Txn: tx}}},
+			Round: round,
+			Intra: i,
+			Key:   fmt.Sprintf("%d-%d", round, i),
+		}})
+	}
+	return b
+}
+
+//count returns the number of rows of a table in a round
+func count(t *testing.T, s *pgSink, table string, round uint64) int {
+	var n int
+	if err := s.pool.QueryRow(context.Background(), "SELECT count(*) FROM "+table+" WHERE round = $1", int64(round)).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestHandleBlock(t *testing.T) {
+	s := testSink(t)
+	ctx := context.Background()
+	if _, err := s.LastCommittedRound(ctx); !errors.Is(err, sink.ErrNoCheckpoint) {
+		t.Fatalf("empty checkpoint: %v", err)
+	}
+
+	//the retried round and an older round are skipped
+	for _, b := range []*sink.Block{testBlock(5, "A", "B"), testBlock(5, "C"), testBlock(4, "D")} {
+		if err := s.HandleBlock(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for table, want := range map[string]int{"blocks": 1, "txns": 2, "txn_participation": 4} {
+		if n := count(t, s, table, 5); n != want {
+			t.Errorf("%s has %d rows of round 5, want %d", table, n, want)
+		}
+	}
+	if n := count(t, s, "txns", 4); n != 0 {
+		t.Errorf("txns has %d rows of round 4 below the checkpoint", n)
+	}
+	var txid string
+	if err := s.pool.QueryRow(ctx, "SELECT txid FROM txns WHERE round = 5 AND intra = 1").Scan(&txid); err != nil || txid != "B" {
+		t.Errorf("txn 5-1 is %q, %v", txid, err)
+	}
+
+	//filtered out blocks advance the checkpoint without a blocks row
+	filtered := testBlock(6)
+	filtered.Msgs = nil
+	if err := s.HandleBlock(ctx, filtered); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, s, "blocks", 6); n != 0 {
+		t.Errorf("blocks has %d rows of filtered round 6", n)
+	}
+	last, err := s.LastCommittedRound(ctx)
+	if err != nil || last != 6 {
+		t.Errorf("LastCommittedRound = %d, %v", last, err)
+	}
+}
+
+func TestSingleTransaction(t *testing.T) {
+	s := testSink(t)
+	ctx := context.Background()
+	if err := s.HandleBlock(ctx, testBlock(5, "A")); err != nil {
+		t.Fatal(err)
+	}
+	//text columns reject NUL, the second txn fails the whole round
+	if err := s.HandleBlock(ctx, testBlock(6, "B", "C\x00")); err == nil {
+		t.Fatal("invalid txn was stored")
+	}
+	for _, table := range []string{"blocks", "txns", "txn_participation"} {
+		if n := count(t, s, table, 6); n != 0 {
+			t.Errorf("%s has %d rows of the failed round", table, n)
+		}
+	}
+	last, err := s.LastCommittedRound(ctx)
+	if err != nil || last != 5 {
+		t.Errorf("LastCommittedRound = %d, %v after failed round", last, err)
+	}
+
+	if err := s.HandleBlock(ctx, testBlock(6, "B", "C")); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, s, "txns", 6); n != 2 {
+		t.Errorf("txns has %d rows of the retried round, want 2", n)
+	}
+}
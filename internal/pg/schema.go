@@ -0,0 +1,63 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package pg
+
+//schema is created on start, statements are idempotent
+const schema = `
+CREATE TABLE IF NOT EXISTS blocks (
+	round      BIGINT PRIMARY KEY,
+	ts         TIMESTAMPTZ NOT NULL,
+	genesis_id TEXT NOT NULL,
+	txn_count  INT NOT NULL,
+	header     JSONB NOT NULL,
+	raw        BYTEA
+);
+
+CREATE TABLE IF NOT EXISTS txns (
+	round    BIGINT NOT NULL,
+	intra    INT NOT NULL,
+	txid     TEXT NOT NULL,
+	type     TEXT NOT NULL,
+	sender   TEXT NOT NULL,
+	receiver TEXT,
+	amount   NUMERIC(20),
+	asset    BIGINT,
+	app      BIGINT,
+	fee      BIGINT NOT NULL,
+	grp      TEXT,
+	note     BYTEA,
+	ts       TIMESTAMPTZ NOT NULL,
+	txn      JSONB NOT NULL,
+	PRIMARY KEY (round, intra)
+);
+CREATE INDEX IF NOT EXISTS txns_txid ON txns (txid);
+CREATE INDEX IF NOT EXISTS txns_sender ON txns (sender, round);
+
+-- same keys as the pub/sub channels: acc, asa, app, note (base64 prefix) and grp
+CREATE TABLE IF NOT EXISTS txn_participation (
+	kind  TEXT NOT NULL,
+	key   TEXT NOT NULL,
+	round BIGINT NOT NULL,
+	intra INT NOT NULL,
+	PRIMARY KEY (kind, key, round, intra)
+);
+
+CREATE TABLE IF NOT EXISTS checkpoint (
+	name    TEXT PRIMARY KEY,
+	round   BIGINT NOT NULL,
+	updated TIMESTAMPTZ NOT NULL
+);
+`
@@ -0,0 +1,304 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	gps "cloud.google.com/go/pubsub"
+	"github.com/algonode/algostreamer/internal/sink"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+)
+
+//maxAttrs is the Pub/Sub limit of attributes per message
+const maxAttrs = 100
+
+type BatchConfig struct {
+	//Count, Bytes and Delay (milliseconds) trigger sending a batch, whichever comes first
+	Count int `json:"count"`
+	Bytes int `json:"bytes"`
+	Delay int `json:"delay"`
+	//Goroutines publishing batches concurrently
+	Goroutines int `json:"goroutines"`
+}
+
+type PubSubConfig struct {
+	Project string `json:"project"`
+	//Credentials is a service account JSON file, defaults to application default credentials
+	Credentials string `json:"credentials"`
+	//Emulator is the emulator host:port, PUBSUB_EMULATOR_HOST works as well
+	Emulator string `json:"emulator"`
+	//Topic receives txns, Blocks and Status topics are optional
+	Topic  string `json:"topic"`
+	Blocks string `json:"blocks"`
+	Status string `json:"status"`
+	//Create adds missing topics
+	Create bool         `json:"create"`
+	Batch  *BatchConfig `json:"batch"`
+}
+
+type pubsubSink struct {
+	sink.NoCheckpoint
+	name   string
+	cfg    *PubSubConfig
+	cl     *gps.Client
+	mu     sync.Mutex
+	topics map[string]*gps.Topic
+}
+
+func init() {
+	sink.Register("pubsub", func() sink.Sink { return &pubsubSink{} })
+}
+
+func (s *pubsubSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &PubSubConfig{}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, s.cfg); err != nil {
+			return fmt.Errorf("[PUBSUB] invalid config: %s", err)
+		}
+	}
+	if s.cfg.Project == "" || s.cfg.Topic == "" {
+		return fmt.Errorf("[PUBSUB] project and topic are required")
+	}
+
+	opts := []option.ClientOption{}
+	if s.cfg.Emulator != "" {
+		conn, err := grpc.Dial(s.cfg.Emulator, grpc.WithInsecure())
+		if err != nil {
+			return fmt.Errorf("[PUBSUB] %s", err)
+		}
+		opts = append(opts, option.WithGRPCConn(conn), option.WithTelemetryDisabled())
+	} else if s.cfg.Credentials != "" {
+		opts = append(opts, option.WithCredentialsFile(s.cfg.Credentials))
+	}
+	cl, err := gps.NewClient(ctx, s.cfg.Project, opts...)
+	if err != nil {
+		return fmt.Errorf("[PUBSUB] %s", err)
+	}
+	s.cl = cl
+	s.topics = make(map[string]*gps.Topic)
+	for _, id := range []string{s.cfg.Topic, s.cfg.Blocks, s.cfg.Status} {
+		if id == "" {
+			continue
+		}
+		if _, err := s.topic(ctx, id); err != nil {
+			cl.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+//topic returns a publisher for the topic ID, rules may route to other topics
+func (s *pubsubSink) topic(ctx context.Context, id string) (*gps.Topic, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.topics[id]; ok {
+		return t, nil
+	}
+	t := s.cl.Topic(id)
+	ok, err := t.Exists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[PUBSUB] topic %s: %s", id, err)
+	}
+	if !ok {
+		if !s.cfg.Create {
+			return nil, fmt.Errorf("[PUBSUB] topic %s does not exist", id)
+		}
+		if t, err = s.cl.CreateTopic(ctx, id); err != nil {
+			return nil, fmt.Errorf("[PUBSUB] creating topic %s: %s", id, err)
+		}
+		fmt.Fprintf(os.Stderr, "[INFO][PUBSUB][%s] topic %s created\n", s.name, id)
+	}
+	t.EnableMessageOrdering = true
+	if b := s.cfg.Batch; b != nil {
+		if b.Count > 0 {
+			t.PublishSettings.CountThreshold = b.Count
+		}
+		if b.Bytes > 0 {
+			t.PublishSettings.ByteThreshold = b.Bytes
+		}
+		if b.Delay > 0 {
+			t.PublishSettings.DelayThreshold = time.Duration(b.Delay) * time.Millisecond
+		}
+		if b.Goroutines > 0 {
+			t.PublishSettings.NumGoroutines = b.Goroutines
+		}
+	}
+	s.topics[id] = t
+	return t, nil
+}
+
+type pending struct {
+	t   *gps.Topic
+	key string
+	res *gps.PublishResult
+}
+
+//HandleBlock publishes all messages of the block and waits for every result.
+//Txns use the sender address as ordering key so each account's txns arrive in order.
+func (s *pubsubSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	start := time.Now()
+	round := uint64(b.Block.Round)
+	var sent []pending
+
+	publish := func(id string, m *gps.Message) error {
+		t, err := s.topic(ctx, id)
+		if err != nil {
+			return err
+		}
+		sent = append(sent, pending{t: t, key: m.OrderingKey, res: t.Publish(ctx, m)})
+		return nil
+	}
+
+	for _, tx := range b.Txns {
+		body, err := tx.Encode(tx.TxWrap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][PUBSUB] %s\n", err)
+			continue
+		}
+		id := tx.Topic
+		if id == "" {
+			id = s.cfg.Topic
+		}
+		if err := publish(id, txMessage(tx, body)); err != nil {
+			return err
+		}
+	}
+	for i := range b.Msgs {
+		id := b.Msgs[i].Topic
+		if id == "" {
+			id = s.cfg.Blocks
+		}
+		if id == "" {
+			continue
+		}
+		body, err := b.Msgs[i].Encode(b.Payload())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][PUBSUB] %s\n", err)
+			continue
+		}
+		if err := publish(id, blockMessage(round, body)); err != nil {
+			return err
+		}
+	}
+
+	if err := s.wait(ctx, sent); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "[INFO][PUBSUB][%s] Block %d published in %s (%d msg)\n", s.name, round, time.Since(start), len(sent))
+	return nil
+}
+
+//txMessage sets filterable txn attributes, the sender address is the ordering key
+func txMessage(tx *sink.Tx, body []byte) *gps.Message {
+	txn := &tx.Txn.Txn
+	attrs := map[string]string{
+		"type":   string(txn.Type),
+		"sender": txn.Sender.String(),
+		"round":  strconv.FormatUint(tx.Round, 10),
+		"intra":  strconv.Itoa(tx.Intra),
+		"txid":   tx.TxId,
+	}
+	//one attribute per subscription key, filter with attributes:acc_<ADDR>
+	for _, k := range sink.TopicKeys(tx.TxWrap) {
+		if len(attrs) == maxAttrs {
+			fmt.Fprintf(os.Stderr, "[WARN][PUBSUB] txn %s has too many topics, attributes truncated\n", tx.TxId)
+			break
+		}
+		attrs[k.Kind+"_"+k.Value] = ""
+	}
+	return &gps.Message{Data: body, Attributes: attrs, OrderingKey: txn.Sender.String()}
+}
+
+//blockMessage uses a single ordering key so blocks arrive in round order
+func blockMessage(round uint64, body []byte) *gps.Message {
+	return &gps.Message{Data: body, Attributes: map[string]string{"type": "block", "round": strconv.FormatUint(round, 10)}, OrderingKey: "block"}
+}
+
+//wait returns the first publish error. Pub/Sub pauses an ordering key after an error,
+//keys are resumed so the retried block can publish again.
+func (s *pubsubSink) wait(ctx context.Context, sent []pending) error {
+	var first error
+	for _, p := range sent {
+		if _, err := p.res.Get(ctx); err != nil {
+			if first == nil {
+				first = fmt.Errorf("[PUBSUB] publish: %s", err)
+			}
+			p.t.ResumePublish(p.key)
+		}
+	}
+	return first
+}
+
+func (s *pubsubSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	var sent []pending
+	for i := range status.Msgs {
+		id := status.Msgs[i].Topic
+		if id == "" {
+			id = s.cfg.Status
+		}
+		if id == "" {
+			continue
+		}
+		body, err := status.Msgs[i].Encode(status.Status)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][PUBSUB] %s\n", err)
+			continue
+		}
+		t, err := s.topic(ctx, id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][PUBSUB] %s\n", err)
+			return err
+		}
+		m := &gps.Message{Data: body, Attributes: map[string]string{"type": "status", "node": status.NodeId}, OrderingKey: status.NodeId}
+		sent = append(sent, pending{t: t, key: m.OrderingKey, res: t.Publish(ctx, m)})
+	}
+	if err := s.wait(ctx, sent); err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][PUBSUB] %s\n", err)
+		return err
+	}
+	return nil
+}
+
+func (s *pubsubSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.topics {
+		t.Flush()
+	}
+	return nil
+}
+
+func (s *pubsubSink) Close(ctx context.Context) error {
+	if s.cl == nil {
+		return nil
+	}
+	s.mu.Lock()
+	for _, t := range s.topics {
+		t.Stop()
+	}
+	s.mu.Unlock()
+	return s.cl.Close()
+}
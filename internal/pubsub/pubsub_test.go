@@ -0,0 +1,100 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package pubsub
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+var (
+	alice = types.Address{1}
+	bob   = types.Address{2}
+)
+
+func testTx(tx types.Transaction) *sink.Tx {
+	return &sink.Tx{TxWrap: &algod.TxWrap{TxId: "TXID", Txn: &types.SignedTxnInBlock{SignedTxnWithAD: types.SignedTxnWithAD{SignedTxn: types.SignedTxn{Txn: tx}}}, Round: 10, Intra: 2, Key: "10-2"}}
+}
+
+func TestTxMessage(t *testing.T) {
+	pay := types.Transaction{Type: types.PaymentTx}
+	pay.Sender, pay.Receiver = alice, bob
+	axfer := types.Transaction{Type: types.AssetTransferTx}
+	axfer.Sender, axfer.AssetReceiver, axfer.XferAsset = bob, alice, 7
+	appl := types.Transaction{Type: types.ApplicationCallTx}
+	appl.Sender, appl.ApplicationID = alice, 10
+
+	common := func(typ string, sender types.Address) map[string]string {
+		return map[string]string{"type": typ, "sender": sender.String(), "round": "10", "intra": "2", "txid": "TXID"}
+	}
+	tests := []struct {
+		name string
+		tx   types.Transaction
+		keys []string
+	}{
+		{"pay", pay, []string{"acc_" + alice.String(), "acc_" + bob.String()}},
+		{"axfer", axfer, []string{"acc_" + alice.String(), "acc_" + bob.String(), "asa_7"}},
+		{"appl", appl, []string{"acc_" + alice.String(), "app_10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := txMessage(testTx(tt.tx), []byte("{}"))
+			want := common(string(tt.tx.Type), tt.tx.Sender)
+			for _, k := range tt.keys {
+				want[k] = ""
+			}
+			if !reflect.DeepEqual(m.Attributes, want) {
+				t.Errorf("attributes %v, want %v", m.Attributes, want)
+			}
+			if m.OrderingKey != tt.tx.Sender.String() {
+				t.Errorf("ordering key %s, want sender", m.OrderingKey)
+			}
+			if string(m.Data) != "{}" {
+				t.Errorf("data %s", m.Data)
+			}
+		})
+	}
+}
+
+func TestTxMessageTruncated(t *testing.T) {
+	appl := types.Transaction{Type: types.ApplicationCallTx}
+	appl.Sender, appl.ApplicationID = alice, 10
+	for i := 0; i < maxAttrs; i++ {
+		appl.Accounts = append(appl.Accounts, types.Address{byte(i), 1})
+	}
+	m := txMessage(testTx(appl), nil)
+	if len(m.Attributes) != maxAttrs {
+		t.Errorf("%d attributes, want %d", len(m.Attributes), maxAttrs)
+	}
+	if m.Attributes["txid"] != "TXID" || m.Attributes["sender"] != alice.String() {
+		t.Errorf("txn attributes were truncated: %v", m.Attributes)
+	}
+}
+
+func TestBlockMessage(t *testing.T) {
+	m := blockMessage(10, []byte("{}"))
+	if want := map[string]string{"type": "block", "round": "10"}; !reflect.DeepEqual(m.Attributes, want) {
+		t.Errorf("attributes %v, want %v", m.Attributes, want)
+	}
+	//blocks share one key so they arrive in round order
+	if m.OrderingKey != "block" {
+		t.Errorf("ordering key %s", m.OrderingKey)
+	}
+}
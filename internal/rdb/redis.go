@@ -17,18 +17,16 @@ package rdb
 
 import (
 	"context"
-	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"os"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/algonode/algostreamer/internal/algod"
-	"github.com/algonode/algostreamer/internal/utils"
-	"github.com/algorand/go-algorand-sdk/types"
+	"github.com/algonode/algostreamer/internal/sink"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -46,66 +44,130 @@ type RedisConfig struct {
 	DB       int    `json:"db"`
 }
 
-func RedisPusher(ctx context.Context, cfg *RedisConfig, blocks chan *algod.BlockWrap, status chan *algod.Status) error {
+type redisSink struct {
+	cfg  *RedisConfig
+	rc   *redis.Client
+	qlen func() int
+}
 
-	var rc *redis.Client = nil
+func init() {
+	sink.Register("redis", func() sink.Sink { return &redisSink{qlen: func() int { return 0 }} })
+}
 
-	if cfg == nil {
-		return fmt.Errorf("[REDIS] redis config is missing")
-	}
-	rc = redis.NewClient(&redis.Options{
+func newClient(cfg *RedisConfig, poolSize int) *redis.Client {
+	return redis.NewClient(&redis.Options{
 		Addr:       cfg.Addr,
 		Password:   cfg.Password,
 		Username:   cfg.Username,
 		DB:         cfg.DB,
 		MaxRetries: 0,
-		PoolSize:   50,
+		PoolSize:   poolSize,
 	})
+}
 
-	go func() {
-		for {
-			select {
-			case s := <-status:
-				if rc != nil {
-					handleStatusUpdate(ctx, s, rc, cfg)
-				}
-			case b := <-blocks:
-				for {
-					//No OPA stuff yet - just populate REDIS streams
-					err := handleBlockRedis(ctx, b, rc, cfg, len(blocks))
-					if err == nil {
-						// if len(blocks) == 0 {
-						// 	os.Exit(0)
-						// }
-						break
-					}
-					time.Sleep(time.Second)
-				}
+func (s *redisSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	if len(cfg) == 0 {
+		return fmt.Errorf("[REDIS] redis config is missing")
+	}
+	s.cfg = &RedisConfig{}
+	if err := json.Unmarshal(cfg, s.cfg); err != nil {
+		return fmt.Errorf("[REDIS] invalid config: %s", err)
+	}
+	s.rc = newClient(s.cfg, 50)
+	return nil
+}
 
-			case <-ctx.Done():
-			}
+func (s *redisSink) SetQueueLen(qlen func() int) {
+	s.qlen = qlen
+}
 
+func (s *redisSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	return handleBlockRedis(ctx, b, s.rc, s.cfg, s.qlen())
+}
+
+func (s *redisSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	var err error
+	for i := range status.Msgs {
+		if status.Msgs[i].Topic == "" {
+			err = handleStatusUpdate(ctx, status.Status, s.rc, s.cfg)
+			continue
 		}
-	}()
+		j, jerr := status.Msgs[i].Encode(status.Status)
+		if jerr != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][REDIS] %s\n", jerr)
+			continue
+		}
+		if perr := s.rc.Publish(ctx, status.Msgs[i].Topic, string(j)).Err(); perr != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][REDIS] %s\n", perr)
+			err = perr
+		}
+	}
+	return err
+}
+
+func (s *redisSink) Flush(ctx context.Context) error {
 	return nil
 }
 
+func (s *redisSink) Close(ctx context.Context) error {
+	return s.rc.Close()
+}
+
+func (s *redisSink) LastCommittedRound(ctx context.Context) (uint64, error) {
+	return RedisGetLastBlock(ctx, s.cfg)
+}
+
+//ReadBlocks pages through the msgpack blocks kept in xblock-v2
+func (s *redisSink) ReadBlocks(ctx context.Context, from uint64, to uint64, fn func(*algod.BlockWrap) error) error {
+	stop := "+"
+	if to > 0 {
+		stop = fmt.Sprintf("%d-0", to)
+	}
+	for {
+		msgs, err := s.rc.XRangeN(ctx, "xblock-v2", fmt.Sprintf("%d-0", from), stop, 100).Result()
+		if err != nil {
+			return fmt.Errorf("[REDIS] reading blocks: %s", err)
+		}
+		for _, m := range msgs {
+			//entry IDs are <round>-0, the next page starts after every entry read, decodable or not
+			r, err := strconv.ParseUint(strings.SplitN(m.ID, "-", 2)[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("[REDIS] invalid block id %s", m.ID)
+			}
+			from = r + 1
+			raw, ok := m.Values["msgpack"].(string)
+			if !ok {
+				continue
+			}
+			bw, err := algod.DecodeBlockRaw([]byte(raw), "redis")
+			if err != nil {
+				return fmt.Errorf("[REDIS] block %s: %s", m.ID, err)
+			}
+			if err := fn(bw); err != nil {
+				return err
+			}
+		}
+		if len(msgs) < 100 {
+			return nil
+		}
+	}
+}
+
 func RedisGetLastBlock(ctx context.Context, cfg *RedisConfig) (uint64, error) {
 
 	if cfg == nil {
 		return 0, fmt.Errorf("[REDIS] redis config is missing")
 	}
-	rc := redis.NewClient(&redis.Options{
-		Addr:       cfg.Addr,
-		Password:   cfg.Password,
-		Username:   cfg.Username,
-		DB:         cfg.DB,
-		MaxRetries: 0,
-	})
+	rc := newClient(cfg, 0)
+	defer rc.Close()
 
 	msg, err := rc.XRevRangeN(ctx, "xblock-v2", "+", "-", 1).Result()
-	if err != nil || len(msg) < 1 {
-		return 0, fmt.Errorf("[REDIS] error getting last element \n", err)
+	if err != nil {
+		return 0, fmt.Errorf("[REDIS] error getting last element %v\n", err)
+	}
+	//fresh redis, let other sinks decide where to resume
+	if len(msg) < 1 {
+		return 0, sink.ErrNoCheckpoint
 	}
 	a := strings.Split(msg[0].ID, "-")
 	if len(a) < 1 {
@@ -148,133 +210,32 @@ func handleStatusUpdate(ctx context.Context, status *algod.Status, rc *redis.Cli
 	return nil
 }
 
-type TxWrap struct {
-	TxId  string                  `json:"txid"`
-	Txn   *types.SignedTxnInBlock `json:"txn"`
-	Round uint64                  `json:"round"`
-	Intra int                     `json:"intra"`
-	Key   string                  `json:"xtx-v2"`
-}
-
-func getTopics(txw *TxWrap) []string {
-	t := make(map[string]struct{})
-
-	addACC := func(a types.Address) {
-		if a.IsZero() {
-			return
-		}
-		t["ACC:"+a.String()] = struct{}{}
-	}
-
-	addASA := func(a types.AssetIndex) {
-		if a == 0 {
-			return
-		}
-		t[fmt.Sprintf("ASA:%d", uint64(a))] = struct{}{}
-	}
-
-	addAPP := func(a types.AppIndex) {
-		if a == 0 {
-			return
-		}
-		t[fmt.Sprintf("APP:%d", uint64(a))] = struct{}{}
-	}
-
-	tx := &txw.Txn.Txn
-	addACC(tx.Sender)
-	addACC(txw.Txn.AuthAddr)
-	switch tx.Type {
-	case types.PaymentTx:
-		addACC(tx.Receiver)
-		addACC(tx.CloseRemainderTo)
-	case types.AssetTransferTx:
-		addACC(tx.AssetSender)
-		addACC(tx.AssetReceiver)
-		addACC(tx.CloseRemainderTo)
-		addASA(tx.XferAsset)
-	case types.AssetConfigTx:
-		addACC(tx.AssetConfigTxnFields.AssetParams.Manager)
-		addACC(tx.AssetConfigTxnFields.AssetParams.Reserve)
-		addACC(tx.AssetConfigTxnFields.AssetParams.Clawback)
-		addACC(tx.AssetConfigTxnFields.AssetParams.Freeze)
-		addASA(tx.ConfigAsset)
-	case types.AssetFreezeTx:
-		addACC(tx.AssetFreezeTxnFields.FreezeAccount)
-		addASA(tx.FreezeAsset)
-	case types.ApplicationCallTx:
-		addAPP(tx.ApplicationID)
-		for i := range tx.ForeignApps {
-			addAPP(tx.ForeignApps[i])
-		}
-		for i := range tx.ForeignAssets {
-			addASA(tx.ForeignAssets[i])
-		}
-		for i := range tx.Accounts {
-			addACC(tx.Accounts[i])
-		}
-	}
-	//Allow subscriptions based on note prefix (up to 32 chars in base64)
-	t["NOTE:"+getNotePrefix(txw, 32)] = struct{}{}
-	if tx.Group != (types.Digest{}) {
-		t["GRP:"+base64.StdEncoding.EncodeToString(tx.Group[:])] = struct{}{}
-	}
-
-	topics := make([]string, len(t))
-	for k := range t {
-		if len(k) > 0 {
-			topics = append(topics, k)
-		}
-	}
-
-	return topics
-}
-func getNotePrefix(txw *TxWrap, l int) string {
-	nb64 := base64.StdEncoding.EncodeToString(txw.Txn.Txn.Note)
-	if l > len(nb64) {
-		return nb64
-	}
-	return nb64[:l]
-}
-
-func genTopic(txw *TxWrap) string {
-	topics := getTopics(txw)
-	sort.Strings(topics)
-	return fmt.Sprintf("TX:%s;%s", txw.TxId, strings.Join(topics, ";"))
-}
-
-func commitPaySet(ctx context.Context, b *algod.BlockWrap, rc *redis.Client, publish bool) {
-	if len(b.Block.Payset) == 0 {
+func commitPaySet(ctx context.Context, b *sink.Block, rc *redis.Client, publish bool) {
+	if len(b.Txns) == 0 {
 		return
 	}
 
 	pipe := rc.Pipeline()
 
-	for i := range b.Block.Payset {
-		txn := &b.Block.Payset[i]
-		//I just love how easy is to get txId nowadays ;)
-		txId, err := algod.DecodeTxnId(b.Block.BlockHeader, txn)
+	for _, tx := range b.Txns {
+		jTx, err := tx.Encode(tx.TxWrap)
+
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "[!ERR][REDIS] %s\n", err)
 			continue
 		}
 
-		txw := &TxWrap{
-			TxId:  txId,
-			Txn:   txn,
-			Round: uint64(b.Block.Round),
-			Intra: i,
-			Key:   fmt.Sprintf("%d-%d", uint64(b.Block.Round), i),
-		}
-		jTx, err := utils.EncodeJson(txw)
-
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "[!ERR][REDIS] %s\n", err)
+		//rules picked a custom channel
+		if tx.Topic != "" {
+			if publish {
+				pipe.Publish(ctx, tx.Topic, string(jTx))
+			}
 			continue
 		}
 
 		if err := pipe.XAdd(ctx, &redis.XAddArgs{
 			Stream: "xtx-v2",
-			ID:     fmt.Sprintf("%d-%d", b.Block.Round, i),
+			ID:     tx.Key,
 			MaxLen: MAX_TXN,
 			Approx: true,
 			Values: map[string]interface{}{"json": string(jTx)},
@@ -285,8 +246,7 @@ func commitPaySet(ctx context.Context, b *algod.BlockWrap, rc *redis.Client, pub
 		}
 
 		if publish {
-			topic := genTopic(txw)
-			pipe.Publish(ctx, topic, string(jTx))
+			pipe.Publish(ctx, tx.Channel(), string(jTx))
 		}
 
 	}
@@ -338,7 +298,10 @@ func updateStats(ctx context.Context, b *algod.BlockWrap, rc *redis.Client) {
 	}
 }
 
-func commitBlock(ctx context.Context, b *algod.BlockWrap, rc *redis.Client) (first bool) {
+//commitBlock always stores the raw block in xblock-v2 as it is the resume checkpoint.
+//Routed block messages go to xblock-v2-json or to their pub/sub channel,
+//as the bare block unless a rule supplies the payload.
+func commitBlock(ctx context.Context, b *sink.Block, rc *redis.Client) (first bool) {
 	var wg sync.WaitGroup
 	first = false
 
@@ -347,10 +310,15 @@ func commitBlock(ctx context.Context, b *algod.BlockWrap, rc *redis.Client) (fir
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		jBlock, err := utils.EncodeJson(b.Block)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "[!ERR][REDIS] Error encoding block to json: %s\n", err)
-		} else {
+		for i := range b.Msgs {
+			if b.Msgs[i].Topic != "" {
+				continue
+			}
+			jBlock, err := b.Msgs[i].Encode(b.Block)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[!ERR][REDIS] Error encoding block to json: %s\n", err)
+				continue
+			}
 			if err := rc.XAdd(ctx, &redis.XAddArgs{
 				Stream: "xblock-v2-json",
 				ID:     fmt.Sprintf("%d-0", b.Block.Round),
@@ -382,10 +350,30 @@ func commitBlock(ctx context.Context, b *algod.BlockWrap, rc *redis.Client) (fir
 	}()
 
 	wg.Wait()
+
+	if first {
+		publishBlock(ctx, b, rc)
+	}
 	return first
 }
 
-func handleBlockRedis(ctx context.Context, b *algod.BlockWrap, rc *redis.Client, cfg *RedisConfig, qlen int) error {
+func publishBlock(ctx context.Context, b *sink.Block, rc *redis.Client) {
+	for i := range b.Msgs {
+		if b.Msgs[i].Topic == "" {
+			continue
+		}
+		jBlock, err := b.Msgs[i].Encode(b.Block)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][REDIS] Error encoding block to json: %s\n", err)
+			continue
+		}
+		if err := rc.Publish(ctx, b.Msgs[i].Topic, string(jBlock)).Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][REDIS] %s\n", err)
+		}
+	}
+}
+
+func handleBlockRedis(ctx context.Context, b *sink.Block, rc *redis.Client, cfg *RedisConfig, qlen int) error {
 	start := time.Now()
 
 	//Try to commit new block
@@ -393,7 +381,7 @@ func handleBlockRedis(ctx context.Context, b *algod.BlockWrap, rc *redis.Client,
 	publish := commitBlock(ctx, b, rc)
 	if publish {
 		go func() {
-			updateStats(ctx, b, rc)
+			updateStats(ctx, b.BlockWrap, rc)
 		}()
 	}
 	commitPaySet(ctx, b, rc, publish)
@@ -403,6 +391,6 @@ func handleBlockRedis(ctx context.Context, b *algod.BlockWrap, rc *redis.Client,
 		p = "+"
 	}
 
-	fmt.Fprintf(os.Stderr, "[INFO][REDIS] Block %d@%s processed(%s) in %s (%d txn). QLen:%d\n", uint64(b.Block.Round), time.Unix(b.Block.TimeStamp, 0).UTC().Format(time.RFC3339), p, time.Since(start), len(b.Block.Payset), qlen)
+	fmt.Fprintf(os.Stderr, "[INFO][REDIS] Block %d@%s processed(%s) in %s (%d txn). QLen:%d\n", uint64(b.Block.Round), time.Unix(b.Block.TimeStamp, 0).UTC().Format(time.RFC3339), p, time.Since(start), len(b.Txns), qlen)
 	return nil
 }
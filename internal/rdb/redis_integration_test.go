@@ -0,0 +1,125 @@
+//go:build integration
+// +build integration
+
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+	"github.com/algorand/go-algorand-sdk/encoding/msgpack"
+	"github.com/algorand/go-algorand-sdk/types"
+	"github.com/go-redis/redis/v8"
+)
+
+//testSink connects to REDIS_ADDR and empties its db 15
+func testSink(t *testing.T) *redisSink {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	ctx := context.Background()
+	cfg, _ := json.Marshal(&RedisConfig{Addr: addr, DB: 15})
+	s := &redisSink{}
+	if err := s.Init(ctx, "test", cfg); err != nil {
+		t.Fatal(err)
+	}
+	s.SetQueueLen(func() int { return 0 })
+	if err := s.rc.FlushDB(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close(ctx) })
+	return s
+}
+
+func rawBlock(round uint64) []byte {
+	return msgpack.Encode(models.BlockResponse{Block: types.Block{BlockHeader: types.BlockHeader{Round: types.Round(round)}}})
+}
+
+func TestCheckpoint(t *testing.T) {
+	s := testSink(t)
+	ctx := context.Background()
+	if _, err := s.LastCommittedRound(ctx); !errors.Is(err, sink.ErrNoCheckpoint) {
+		t.Fatalf("empty stream: %v", err)
+	}
+	for round := uint64(1); round <= 3; round++ {
+		raw := rawBlock(round)
+		bw, err := algod.DecodeBlockRaw(raw, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.HandleBlock(ctx, &sink.Block{BlockWrap: bw, Msgs: []sink.Msg{{}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	last, err := s.LastCommittedRound(ctx)
+	if err != nil || last != 3 {
+		t.Errorf("LastCommittedRound = %d, %v", last, err)
+	}
+}
+
+func TestReadBlocks(t *testing.T) {
+	s := testSink(t)
+	ctx := context.Background()
+	//round 100 ends the first page without a msgpack field
+	for round := uint64(1); round <= 150; round++ {
+		values := map[string]interface{}{"msgpack": rawBlock(round)}
+		if round == 100 {
+			values = map[string]interface{}{"other": "x"}
+		}
+		if err := s.rc.XAdd(ctx, &redis.XAddArgs{Stream: "xblock-v2", ID: fmt.Sprintf("%d-0", round), Values: values}).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		from, to    uint64
+		first, last uint64
+		n           int
+	}{
+		{1, 0, 1, 150, 149},
+		{95, 105, 95, 105, 10},
+		{140, 0, 140, 150, 11},
+		{151, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		var got []uint64
+		err := s.ReadBlocks(ctx, tt.from, tt.to, func(bw *algod.BlockWrap) error {
+			got = append(got, uint64(bw.Block.Round))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != tt.n || (tt.n > 0 && (got[0] != tt.first || got[len(got)-1] != tt.last)) {
+			t.Errorf("ReadBlocks(%d, %d) = %v", tt.from, tt.to, got)
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i] <= got[i-1] || got[i] == 100 {
+				t.Errorf("ReadBlocks(%d, %d) = %v", tt.from, tt.to, got)
+				break
+			}
+		}
+	}
+}
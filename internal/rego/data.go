@@ -0,0 +1,148 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+package rego
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/open-policy-agent/opa/util"
+)
+
+//DataSource is a document made available to rules as data.<name>
+//Either a JSON/YAML file or a Redis key - a hash becomes an object,
+//a set becomes an array and a string is parsed as JSON/YAML.
+type DataSource struct {
+	File  string       `json:"file"`
+	Redis *RedisSource `json:"redis"`
+}
+
+//RedisSource points to a single Redis key
+type RedisSource struct {
+	Addr     string `json:"addr"`
+	Username string `json:"user"`
+	Password string `json:"pass"`
+	DB       int    `json:"db"`
+	Key      string `json:"key"`
+}
+
+func (cfg *RedisSource) client() *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:       cfg.Addr,
+		Password:   cfg.Password,
+		Username:   cfg.Username,
+		DB:         cfg.DB,
+		MaxRetries: 0,
+	})
+}
+
+const dataTimeout = time.Second * 10
+
+//loadData builds a fresh store with all data documents
+func loadData(ctx context.Context, sources map[string]*DataSource) (storage.Store, error) {
+	data := make(map[string]interface{}, len(sources))
+	for name, src := range sources {
+		switch name {
+		case "block", "tx", "status":
+			return nil, fmt.Errorf("[REGO] data document %s clashes with rules package", name)
+		}
+		if src == nil {
+			return nil, fmt.Errorf("[REGO] data document %s has no source", name)
+		}
+		var (
+			doc interface{}
+			err error
+		)
+		switch {
+		case src.File != "":
+			doc, err = loadDataFile(src.File)
+		case src.Redis != nil:
+			doc, err = loadDataRedis(ctx, src.Redis)
+		default:
+			err = fmt.Errorf("configure file or redis")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("[REGO] data document %s: %s", name, err)
+		}
+		data[name] = doc
+	}
+	return inmem.NewFromObject(data), nil
+}
+
+func loadDataFile(file string) (interface{}, error) {
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	//handles both JSON and YAML
+	if err := util.Unmarshal(bs, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func loadDataRedis(ctx context.Context, cfg *RedisSource) (interface{}, error) {
+	rc := cfg.client()
+	defer rc.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, dataTimeout)
+	defer cancel()
+
+	t, err := rc.Type(ctx, cfg.Key).Result()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case "hash":
+		h, err := rc.HGetAll(ctx, cfg.Key).Result()
+		if err != nil {
+			return nil, err
+		}
+		doc := make(map[string]interface{}, len(h))
+		for k, v := range h {
+			doc[k] = v
+		}
+		return doc, nil
+	case "set":
+		m, err := rc.SMembers(ctx, cfg.Key).Result()
+		if err != nil {
+			return nil, err
+		}
+		doc := make([]interface{}, len(m))
+		for i := range m {
+			doc[i] = m[i]
+		}
+		return doc, nil
+	case "string":
+		s, err := rc.Get(ctx, cfg.Key).Result()
+		if err != nil {
+			return nil, err
+		}
+		var doc interface{}
+		if err := util.Unmarshal([]byte(s), &doc); err != nil {
+			return nil, err
+		}
+		return doc, nil
+	case "none":
+		return nil, fmt.Errorf("redis key %s does not exist", cfg.Key)
+	}
+	return nil, fmt.Errorf("redis key %s has unsupported type %s", cfg.Key, t)
+}
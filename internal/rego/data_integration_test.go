@@ -0,0 +1,74 @@
+//go:build integration
+// +build integration
+
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+package rego
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLoadDataRedis(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	ctx := context.Background()
+	src := &RedisSource{Addr: addr, DB: 15}
+	rc := src.client()
+	defer rc.Close()
+	if err := rc.FlushDB(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	rc.HSet(ctx, "hash", "A", "whale", "B", "shrimp")
+	rc.SAdd(ctx, "set", "A")
+	rc.Set(ctx, "string", `{"min": 5}`, 0)
+	rc.LPush(ctx, "list", "A")
+
+	tests := []struct {
+		key  string
+		want interface{}
+	}{
+		{"hash", map[string]interface{}{"A": "whale", "B": "shrimp"}},
+		{"set", []interface{}{"A"}},
+		{"string", map[string]interface{}{"min": json.Number("5")}},
+		{"list", nil},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			cfg := *src
+			cfg.Key = tt.key
+			got, err := loadDataRedis(ctx, &cfg)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("got %#v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
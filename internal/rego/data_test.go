@@ -0,0 +1,99 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+package rego
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/open-policy-agent/opa/storage"
+)
+
+func TestLoadData(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := writeRule(t, dir, "accts.json", `{"whales": ["A", "B"], "min": 5}`)
+	yamlFile := writeRule(t, dir, "apps.yaml", "watched:\n  - 10\n  - 11\n")
+	badFile := writeRule(t, dir, "bad.json", `{"whales": [`)
+
+	ctx := context.Background()
+	store, err := loadData(ctx, map[string]*DataSource{"accts": {File: jsonFile}, "apps": {File: yamlFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn, err := store.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Abort(ctx, txn)
+	for path, want := range map[string]interface{}{
+		"/accts/whales": []interface{}{"A", "B"},
+		"/apps/watched": []interface{}{json.Number("10"), json.Number("11")},
+	} {
+		got, err := store.Read(ctx, txn, storage.MustParsePath(path))
+		if err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %#v, want %#v", path, got, want)
+		}
+	}
+
+	for name, sources := range map[string]map[string]*DataSource{
+		"clashes with rules": {"tx": {File: jsonFile}},
+		"no source":          {"accts": nil},
+		"no file or redis":   {"accts": {}},
+		"missing file":       {"accts": {File: dir + "/missing.json"}},
+		"invalid file":       {"accts": {File: badFile}},
+	} {
+		if _, err := loadData(ctx, sources); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
+
+func TestRefreshData(t *testing.T) {
+	dir := t.TempDir()
+	data := writeRule(t, dir, "cfg.json", `{"topic": "a"}`)
+	cfg := &OpaConfig{
+		Rules:   RegoRulesMap{Tx: writeRule(t, dir, "tx.rego", "package tx\ntopic = data.cfg.topic { true }")},
+		Data:    map[string]*DataSource{"cfg": {File: data}},
+		Workers: 1,
+	}
+	if err := CompileCfg(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := txTopic(t, cfg); got != "a" {
+		t.Fatalf("topic %q, want a", got)
+	}
+
+	writeRule(t, dir, "cfg.json", `{"topic": "b"}`)
+	cfg.refreshData(context.Background())
+	if got := txTopic(t, cfg); got != "b" {
+		t.Errorf("topic %q after refresh, want b", got)
+	}
+
+	//failed refresh keeps previous data
+	c := cfg.Compilers()
+	writeRule(t, dir, "cfg.json", `{"topic": `)
+	cfg.refreshData(context.Background())
+	if cfg.Compilers() != c {
+		t.Error("failed refresh swapped the data")
+	}
+	if got := txTopic(t, cfg); got != "b" {
+		t.Errorf("topic %q after failed refresh, want b", got)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+package rego
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	decisionQueue  = 10_000
+	decisionMaxLen = 100_000
+)
+
+//DecisionLogConfig writes every decision to a file or a Redis stream
+type DecisionLogConfig struct {
+	File string `json:"file"`
+	//Redis Key is the stream name
+	Redis *RedisSource `json:"redis"`
+	//MaxLen caps the Redis stream length
+	MaxLen int64 `json:"maxlen"`
+}
+
+type decisionEntry struct {
+	Ts        time.Time `json:"ts"`
+	Path      string    `json:"path"`
+	InputHash string    `json:"input_hash"`
+	Result    []Route   `json:"result"`
+	Error     string    `json:"error,omitempty"`
+	LatencyUs int64     `json:"latency_us"`
+}
+
+//decisionLog queues entries for a background writer so logging never stalls the stream
+type decisionLog struct {
+	ch      chan *decisionEntry
+	dropped uint64
+}
+
+func (dl *decisionLog) record(module string, input []byte, routes []Route, err error, latency time.Duration) {
+	if dl == nil {
+		return
+	}
+	h := sha256.Sum256(input)
+	e := &decisionEntry{
+		Ts:        time.Now().UTC(),
+		Path:      "data." + module,
+		InputHash: hex.EncodeToString(h[:]),
+		Result:    routes,
+		LatencyUs: latency.Microseconds(),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	select {
+	case dl.ch <- e:
+	default:
+		atomic.AddUint64(&dl.dropped, 1)
+	}
+}
+
+//startDecisionLog opens the configured destination and spawns the writer
+func startDecisionLog(ctx context.Context, cfg *DecisionLogConfig) (*decisionLog, error) {
+	dl := &decisionLog{ch: make(chan *decisionEntry, decisionQueue)}
+	switch {
+	case cfg.File != "":
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("[REGO] decision log: %s", err)
+		}
+		go dl.writeFile(ctx, f)
+	case cfg.Redis != nil:
+		if cfg.Redis.Key == "" {
+			return nil, fmt.Errorf("[REGO] decision log: redis stream key is missing")
+		}
+		maxLen := cfg.MaxLen
+		if maxLen <= 0 {
+			maxLen = decisionMaxLen
+		}
+		go dl.writeRedis(ctx, cfg.Redis, maxLen)
+	default:
+		return nil, fmt.Errorf("[REGO] decision log: configure file or redis")
+	}
+	return dl, nil
+}
+
+func (dl *decisionLog) writeFile(ctx context.Context, f *os.File) {
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	defer w.Flush()
+	enc := json.NewEncoder(w)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case e := <-dl.ch:
+			if err := enc.Encode(e); err != nil {
+				fmt.Fprintf(os.Stderr, "[!ERR][REGO] decision log: %s\n", err)
+			}
+		case <-ticker.C:
+			if err := w.Flush(); err != nil {
+				fmt.Fprintf(os.Stderr, "[!ERR][REGO] decision log: %s\n", err)
+			}
+			dl.reportDropped()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (dl *decisionLog) writeRedis(ctx context.Context, cfg *RedisSource, maxLen int64) {
+	rc := cfg.client()
+	defer rc.Close()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case e := <-dl.ch:
+			j, err := json.Marshal(e)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[!ERR][REGO] decision log: %s\n", err)
+				continue
+			}
+			if err := rc.XAdd(ctx, &redis.XAddArgs{
+				Stream: cfg.Key,
+				MaxLen: maxLen,
+				Approx: true,
+				Values: map[string]interface{}{"json": string(j)},
+			}).Err(); err != nil && ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "[!ERR][REGO] decision log: %s\n", err)
+			}
+		case <-ticker.C:
+			dl.reportDropped()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (dl *decisionLog) reportDropped() {
+	if d := atomic.SwapUint64(&dl.dropped, 0); d > 0 {
+		fmt.Fprintf(os.Stderr, "[WARN][REGO] decision log is falling behind, dropped %d entries\n", d)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+package rego
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecisionLog(t *testing.T) {
+	var nilLog *decisionLog
+	nilLog.record("tx", nil, nil, nil, 0)
+
+	dl := &decisionLog{ch: make(chan *decisionEntry, 2)}
+	dl.record("tx", []byte("{}"), []Route{{Sink: "a"}}, nil, time.Millisecond)
+	dl.record("block", []byte("{}"), nil, errors.New("boom"), time.Millisecond)
+	dl.record("tx", []byte("{}"), nil, nil, time.Millisecond)
+	if dl.dropped != 1 {
+		t.Errorf("dropped %d, want 1", dl.dropped)
+	}
+
+	file := filepath.Join(t.TempDir(), "decisions.ndjson")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		dl.writeFile(ctx, f)
+		close(done)
+	}()
+	for len(dl.ch) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	f, err = os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []decisionEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e decisionEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("%d entries, want 2", len(entries))
+	}
+	//sha256 of {}
+	hash := "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"
+	if e := entries[0]; e.Path != "data.tx" || e.InputHash != hash || !reflect.DeepEqual(e.Result, []Route{{Sink: "a"}}) || e.LatencyUs != 1000 {
+		t.Errorf("entry %+v", e)
+	}
+	if e := entries[1]; e.Path != "data.block" || e.Error != "boom" || e.Result != nil {
+		t.Errorf("entry %+v", e)
+	}
+}
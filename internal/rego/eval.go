@@ -0,0 +1,198 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+package rego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/utils"
+	opa "github.com/open-policy-agent/opa/rego"
+)
+
+//Route sends an event to a sink.
+//Empty Sink means all sinks, empty Topic means sink default
+//and nil Payload means the original event.
+type Route struct {
+	Sink    string      `json:"sink,omitempty"`
+	Topic   string      `json:"topic,omitempty"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+//DefaultRoutes is used for event categories without rules - send everything everywhere
+var DefaultRoutes = []Route{{}}
+
+//decision is what rules in the category package can define:
+//  drop    - true filters out the event
+//  sinks   - limits delivery to the listed sinks
+//  topic   - overrides the sink default topic/channel/stream
+//  payload - replaces the event body
+//  routes  - set of {sink, topic, payload} objects, replaces sinks/topic/payload
+//            so one event can go to several places in different shapes
+type decision struct {
+	Drop    bool        `json:"drop"`
+	Sinks   []string    `json:"sinks"`
+	Topic   string      `json:"topic"`
+	Payload interface{} `json:"payload"`
+	Routes  *[]Route    `json:"routes"`
+}
+
+//EvalBlock runs block rules, the input is the JSON BlockWrap with myid field added
+func (rc *RegoRulesCompilers) EvalBlock(ctx context.Context, b *algod.BlockWrap) ([]Route, error) {
+	if rc == nil || rc.Block == nil {
+		return DefaultRoutes, nil
+	}
+	return rc.run(ctx, "block", b, nil)
+}
+
+//EvalTx runs tx rules, the input is the JSON TxWrap with myid and topics fields added
+func (rc *RegoRulesCompilers) EvalTx(ctx context.Context, txw *algod.TxWrap) ([]Route, error) {
+	if rc == nil || rc.Tx == nil {
+		return DefaultRoutes, nil
+	}
+	return rc.run(ctx, "tx", txw, func(in map[string]interface{}) {
+		in["topics"] = txw.Topics()
+	})
+}
+
+//EvalStatus runs status rules, the input is the JSON Status with myid field added
+func (rc *RegoRulesCompilers) EvalStatus(ctx context.Context, s *algod.Status) ([]Route, error) {
+	if rc == nil || rc.Status == nil {
+		return DefaultRoutes, nil
+	}
+	return rc.run(ctx, "status", s, nil)
+}
+
+//EvalTxns runs tx rules for all txns of a block using a bounded pool of workers.
+//Results are indexed like txns so intra block order is kept.
+func (rc *RegoRulesCompilers) EvalTxns(ctx context.Context, txns []*algod.TxWrap) ([][]Route, []error) {
+	routes := make([][]Route, len(txns))
+	errs := make([]error, len(txns))
+	if rc == nil || rc.Tx == nil {
+		for i := range txns {
+			routes[i] = DefaultRoutes
+		}
+		return routes, errs
+	}
+
+	workers := rc.workers
+	if workers > len(txns) {
+		workers = len(txns)
+	}
+	var (
+		next int64 = -1
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(txns) {
+					return
+				}
+				routes[i], errs[i] = rc.EvalTx(ctx, txns[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return routes, errs
+}
+
+//run builds the input, evaluates the rules and records stats and the decision log
+func (rc *RegoRulesCompilers) run(ctx context.Context, module string, obj interface{}, extra func(map[string]interface{})) ([]Route, error) {
+	start := time.Now()
+	in, raw, err := makeInput(obj, rc.myid)
+	var routes []Route
+	if err == nil {
+		if extra != nil {
+			extra(in)
+		}
+		routes, err = rc.eval(ctx, module, in)
+	}
+	latency := time.Since(start)
+	rc.stats.observe(module, routes, err, latency)
+	rc.log.record(module, raw, routes, err, latency)
+	return routes, err
+}
+
+//makeInput converts algorand objects using their canonical JSON field names,
+//returns the raw JSON as well for the decision log
+func makeInput(obj interface{}, myid string) (map[string]interface{}, []byte, error) {
+	j, err := utils.EncodeJson(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	in := make(map[string]interface{})
+	dec := json.NewDecoder(bytes.NewReader(j))
+	//keep uint64 amounts exact
+	dec.UseNumber()
+	if err := dec.Decode(&in); err != nil {
+		return nil, nil, err
+	}
+	in["myid"] = myid
+	return in, j, nil
+}
+
+func (rc *RegoRulesCompilers) eval(ctx context.Context, module string, input interface{}) ([]Route, error) {
+	pq, ok := rc.queries[module]
+	if !ok {
+		return nil, fmt.Errorf("[REGO][%s] query not prepared", module)
+	}
+	rs, err := pq.Eval(ctx, opa.EvalInput(input))
+	if err != nil {
+		return nil, fmt.Errorf("[REGO][%s] %s", module, err)
+	}
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		//package not defined in the rule file
+		return DefaultRoutes, nil
+	}
+	return parseDecision(rs[0].Expressions[0].Value)
+}
+
+func parseDecision(v interface{}) ([]Route, error) {
+	var d decision
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.UseNumber()
+	if err := dec.Decode(&d); err != nil {
+		return nil, fmt.Errorf("[REGO] invalid decision: %s", err)
+	}
+	if d.Drop {
+		return []Route{}, nil
+	}
+	//an empty routes set is a valid decision to send nothing
+	if d.Routes != nil {
+		return *d.Routes, nil
+	}
+	if len(d.Sinks) == 0 {
+		return []Route{{Topic: d.Topic, Payload: d.Payload}}, nil
+	}
+	routes := make([]Route, 0, len(d.Sinks))
+	for _, s := range d.Sinks {
+		routes = append(routes, Route{Sink: s, Topic: d.Topic, Payload: d.Payload})
+	}
+	return routes, nil
+}
@@ -0,0 +1,115 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+package rego
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/algonode/algostreamer/internal/algod"
+)
+
+//value decodes a JSON document the way OPA results come back, numbers as json.Number
+func value(t *testing.T, s string) interface{} {
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestParseDecision(t *testing.T) {
+	tests := []struct {
+		name     string
+		decision string
+		want     []Route
+	}{
+		{"empty package keeps defaults", `{}`, []Route{{}}},
+		{"drop", `{"drop": true}`, []Route{}},
+		{"drop wins over routes", `{"drop": true, "routes": [{"sink": "a"}]}`, []Route{}},
+		{"not dropped", `{"drop": false, "topic": "t"}`, []Route{{Topic: "t"}}},
+		{"sinks", `{"sinks": ["a", "b"], "topic": "t"}`, []Route{{Sink: "a", Topic: "t"}, {Sink: "b", Topic: "t"}}},
+		{"payload", `{"payload": {"amt": 5}}`, []Route{{Payload: map[string]interface{}{"amt": json.Number("5")}}}},
+		{"empty routes send nothing", `{"routes": []}`, []Route{}},
+		{"routes replace sinks", `{"sinks": ["a"], "topic": "t", "routes": [{"sink": "b"}, {"topic": "u", "payload": "x"}]}`,
+			[]Route{{Sink: "b"}, {Topic: "u", Payload: "x"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDecision(value(t, tt.decision))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDecisionInvalid(t *testing.T) {
+	for _, decision := range []string{
+		`{"drop": "yes"}`,
+		`{"sinks": "a"}`,
+		`{"sinks": [1]}`,
+		`{"topic": 1}`,
+		`{"routes": {"sink": "a"}}`,
+		`{"routes": [{"sink": ["a"]}]}`,
+		`true`,
+	} {
+		if got, err := parseDecision(value(t, decision)); err == nil {
+			t.Errorf("%s: got %+v, want error", decision, got)
+		}
+	}
+}
+
+func TestEvalTxns(t *testing.T) {
+	txns := make([]*algod.TxWrap, 200)
+	for i := range txns {
+		txns[i] = testTx(i)
+	}
+
+	var nilRules *RegoRulesCompilers
+	routes, _ := nilRules.EvalTxns(context.Background(), txns)
+	if len(routes) != len(txns) || !reflect.DeepEqual(routes[0], DefaultRoutes) {
+		t.Errorf("without rules got %d routes, first %+v", len(routes), routes[0])
+	}
+
+	cfg := &OpaConfig{Rules: RegoRulesMap{Tx: writeRule(t, t.TempDir(), "tx.rego", "package tx\ntopic = format_int(input.intra, 10) { true }")}, Workers: 8}
+	if err := CompileCfg(cfg); err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.Compilers()
+	for _, n := range []int{0, 3, len(txns)} {
+		routes, errs := c.EvalTxns(context.Background(), txns[:n])
+		if len(routes) != n || len(errs) != n {
+			t.Fatalf("%d txns: %d routes, %d errors", n, len(routes), len(errs))
+		}
+		for i := range routes {
+			if errs[i] != nil {
+				t.Fatal(errs[i])
+			}
+			if len(routes[i]) != 1 || routes[i][0].Topic != strconv.Itoa(i) {
+				t.Fatalf("%d txns: routes[%d] = %+v", n, i, routes[i])
+			}
+		}
+	}
+}
@@ -15,10 +15,17 @@
 package rego
 
 import (
+	"context"
+	"expvar"
 	"fmt"
 	"os"
+	"runtime"
+	"sync/atomic"
+	"time"
 
 	"github.com/open-policy-agent/opa/ast"
+	opa "github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage"
 )
 
 type RegoRulesMap struct {
@@ -28,33 +35,245 @@ type RegoRulesMap struct {
 }
 
 type RegoRulesCompilers struct {
-	Status *ast.Compiler
-	Block  *ast.Compiler
-	Tx     *ast.Compiler
+	Status  *ast.Compiler
+	Block   *ast.Compiler
+	Tx      *ast.Compiler
+	myid    string
+	store   storage.Store
+	queries map[string]*opa.PreparedEvalQuery
+	workers int
+	stats   evalStats
+	log     *decisionLog
+}
+
+//prepare builds evaluation queries for the current compilers and data,
+//prepared queries are safe for concurrent use by the tx workers
+func (c *RegoRulesCompilers) prepare(ctx context.Context) error {
+	queries := make(map[string]*opa.PreparedEvalQuery, 3)
+	for module, compiler := range map[string]*ast.Compiler{"status": c.Status, "block": c.Block, "tx": c.Tx} {
+		if compiler == nil {
+			continue
+		}
+		opts := []func(*opa.Rego){
+			opa.Compiler(compiler),
+			opa.Query("data." + module),
+		}
+		if c.store != nil {
+			opts = append(opts, opa.Store(c.store))
+		}
+		pq, err := opa.New(opts...).PrepareForEval(ctx)
+		if err != nil {
+			return fmt.Errorf("[REGO][%s] %s", module, err)
+		}
+		queries[module] = &pq
+	}
+	c.queries = queries
+	return nil
 }
 
 type OpaConfig struct {
 	MyID  string       `json:"myid"`
 	Rules RegoRulesMap `json:"rules"`
-	c     RegoRulesCompilers
+	//Watch is the number of seconds between rule file checks
+	Watch int `json:"watch"`
+	//Data documents are available to rules as data.<name>
+	Data map[string]*DataSource `json:"data"`
+	//Refresh is the number of seconds between data reloads
+	Refresh int `json:"refresh"`
+	//Log enables the decision log
+	Log *DecisionLogConfig `json:"log"`
+	//Stats is the number of seconds between evaluation stats lines, negative disables them
+	Stats int `json:"stats"`
+	//Workers is the number of goroutines evaluating txns of a block, defaults to number of CPUs
+	Workers int `json:"workers"`
+	//c holds *RegoRulesCompilers, swapped as a whole on reload
+	c      atomic.Value
+	stamps map[string]fileStamp
+}
+
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+//Compilers returns the current set of compiled rules.
+//Callers should use one snapshot per block so all its txns see the same rules.
+func (cfg *OpaConfig) Compilers() *RegoRulesCompilers {
+	if cfg == nil {
+		return nil
+	}
+	c, _ := cfg.c.Load().(*RegoRulesCompilers)
+	return c
 }
 
 func CompileCfg(cfg *OpaConfig) error {
-	if err := compileRegoFile(cfg.Rules.Status, "status", &cfg.c.Status); err != nil {
-		return err
+	c := &RegoRulesCompilers{myid: cfg.MyID, workers: cfg.Workers, stats: newEvalStats()}
+	if c.workers <= 0 {
+		c.workers = runtime.NumCPU()
 	}
-	if err := compileRegoFile(cfg.Rules.Block, "block", &cfg.c.Block); err != nil {
-		return err
+	cfg.stamps = make(map[string]fileStamp)
+	for _, r := range cfg.rules(c) {
+		cfg.stamp(r.file)
+		if err := compileRegoFile(r.file, r.module, r.compiler); err != nil {
+			return err
+		}
 	}
-	if err := compileRegoFile(cfg.Rules.Tx, "tx", &cfg.c.Tx); err != nil {
+	if c.Status == nil && c.Block == nil && c.Tx == nil {
+		return fmt.Errorf("define OPA rule file for at least one event category (status|block|tx)")
+	}
+	if len(cfg.Data) > 0 {
+		store, err := loadData(context.Background(), cfg.Data)
+		if err != nil {
+			return err
+		}
+		c.store = store
+	}
+	if err := c.prepare(context.Background()); err != nil {
 		return err
 	}
-	if cfg.c.Status == nil && cfg.c.Block == nil && cfg.c.Tx == nil {
-		return fmt.Errorf("define OPA rule file for at least one event category (status|block|tx)")
+	cfg.c.Store(c)
+	return nil
+}
+
+type ruleFile struct {
+	file     string
+	module   string
+	compiler **ast.Compiler
+}
+
+func (cfg *OpaConfig) rules(c *RegoRulesCompilers) []ruleFile {
+	return []ruleFile{
+		{cfg.Rules.Status, "status", &c.Status},
+		{cfg.Rules.Block, "block", &c.Block},
+		{cfg.Rules.Tx, "tx", &c.Tx},
+	}
+}
+
+//stamp records file modification time and size, returns true if they changed
+func (cfg *OpaConfig) stamp(file string) bool {
+	if file == "" {
+		return false
 	}
+	st, err := os.Stat(file)
+	if err != nil {
+		return false
+	}
+	fs := fileStamp{mod: st.ModTime(), size: st.Size()}
+	if cfg.stamps[file] == fs {
+		return false
+	}
+	cfg.stamps[file] = fs
+	return true
+}
+
+//Run starts the decision log and stats reporting, then watches rules and data.
+//Rule files are recompiled when they change or when a signal arrives on reload
+//and data documents are refreshed on schedule or signal.
+//New rules are swapped in as a whole, a file that fails to compile keeps its previous rules
+//and failed data refresh keeps previous data.
+func Run(ctx context.Context, cfg *OpaConfig, reload <-chan os.Signal) error {
+	c := cfg.Compilers()
+	if c == nil {
+		return fmt.Errorf("[REGO] rules are not compiled")
+	}
+	if cfg.Log != nil {
+		dl, err := startDecisionLog(ctx, cfg.Log)
+		if err != nil {
+			return err
+		}
+		next := *c
+		next.log = dl
+		cfg.c.Store(&next)
+	}
+	expvar.Publish("rego", expvar.Func(func() interface{} { return c.stats.Snapshot() }))
+	if cfg.Stats >= 0 {
+		interval := time.Duration(cfg.Stats) * time.Second
+		if interval == 0 {
+			interval = time.Second * 60
+		}
+		go c.stats.logStats(ctx, interval)
+	}
+	cfg.watch(ctx, reload)
 	return nil
 }
 
+func (cfg *OpaConfig) watch(ctx context.Context, reload <-chan os.Signal) {
+	interval := time.Duration(cfg.Watch) * time.Second
+	if interval <= 0 {
+		interval = time.Second * 5
+	}
+	refresh := time.Duration(cfg.Refresh) * time.Second
+	if refresh <= 0 {
+		refresh = time.Second * 60
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		dticker := time.NewTicker(refresh)
+		defer dticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				cfg.reload(false)
+			case <-dticker.C:
+				cfg.refreshData(ctx)
+			case <-reload:
+				fmt.Fprintf(os.Stderr, "[INFO][REGO] reloading rules\n")
+				cfg.reload(true)
+				cfg.refreshData(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (cfg *OpaConfig) refreshData(ctx context.Context) {
+	old := cfg.Compilers()
+	if old == nil || len(cfg.Data) == 0 {
+		return
+	}
+	store, err := loadData(ctx, cfg.Data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][REGO] %s, keeping previous data\n", err)
+		return
+	}
+	next := *old
+	next.store = store
+	if err := next.prepare(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][REGO] %s, keeping previous data\n", err)
+		return
+	}
+	cfg.c.Store(&next)
+}
+
+func (cfg *OpaConfig) reload(force bool) {
+	old := cfg.Compilers()
+	if old == nil {
+		return
+	}
+	next := *old
+	changed := false
+	for _, r := range cfg.rules(&next) {
+		if r.file == "" || (!cfg.stamp(r.file) && !force) {
+			continue
+		}
+		if err := compileRegoFile(r.file, r.module, r.compiler); err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][REGO] %s: %s, keeping previous rules\n", r.file, err)
+			continue
+		}
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	if err := next.prepare(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][REGO] %s, keeping previous rules\n", err)
+		return
+	}
+	cfg.c.Store(&next)
+}
+
 func compileRegoFile(file string, module string, compiler **ast.Compiler) error {
 	if file == "" {
 		return nil
@@ -71,7 +290,7 @@ func compileRegoFile(file string, module string, compiler **ast.Compiler) error
 	if err != nil {
 		return err
 	}
-	fmt.Printf("File %s compiled\n", file)
+	fmt.Fprintf(os.Stderr, "[INFO][REGO] File %s compiled\n", file)
 	*compiler = c
 	return nil
 
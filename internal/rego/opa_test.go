@@ -0,0 +1,102 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+package rego
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+//writeRule writes a rule file into dir and returns its path
+func writeRule(t *testing.T, dir string, name string, src string) string {
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func testTx(intra int) *algod.TxWrap {
+	tx := types.Transaction{Type: types.PaymentTx}
+	tx.Amount = types.MicroAlgos(intra)
+	return &algod.TxWrap{TxId: "TXID", Txn: &types.SignedTxnInBlock{SignedTxnWithAD: types.SignedTxnWithAD{SignedTxn: types.SignedTxn{Txn: tx}}}, Round: 10, Intra: intra}
+}
+
+//txTopic evaluates the current tx rules and returns the topic of the only route
+func txTopic(t *testing.T, cfg *OpaConfig) string {
+	routes, err := cfg.Compilers().EvalTx(context.Background(), testTx(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 {
+		t.Fatalf("routes %+v", routes)
+	}
+	return routes[0].Topic
+}
+
+func TestCompileCfg(t *testing.T) {
+	dir := t.TempDir()
+	if err := CompileCfg(&OpaConfig{}); err == nil {
+		t.Error("no rule files compiled")
+	}
+	bad := writeRule(t, dir, "bad.rego", "package tx\ntopic = {")
+	if err := CompileCfg(&OpaConfig{Rules: RegoRulesMap{Tx: bad}}); err == nil {
+		t.Error("invalid rule file compiled")
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	file := writeRule(t, dir, "tx.rego", "package tx\ntopic = \"a\" { true }")
+	cfg := &OpaConfig{Rules: RegoRulesMap{Tx: file}, Workers: 1}
+	if err := CompileCfg(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := txTopic(t, cfg); got != "a" {
+		t.Fatalf("topic %q, want a", got)
+	}
+
+	//unchanged file keeps the compilers
+	c := cfg.Compilers()
+	cfg.reload(false)
+	if cfg.Compilers() != c {
+		t.Error("unchanged rules were swapped")
+	}
+
+	//a file that fails to compile keeps the previous rules, also on forced reload
+	writeRule(t, dir, "tx.rego", "package tx\ntopic = {")
+	cfg.reload(false)
+	cfg.reload(true)
+	if cfg.Compilers() != c {
+		t.Error("broken rules were swapped in")
+	}
+	if got := txTopic(t, cfg); got != "a" {
+		t.Errorf("topic %q after broken reload, want a", got)
+	}
+
+	writeRule(t, dir, "tx.rego", "package tx\ntopic = \"bb\" { true }")
+	cfg.reload(false)
+	if got := txTopic(t, cfg); got != "bb" {
+		t.Errorf("topic %q after reload, want bb", got)
+	}
+	if c.Tx == cfg.Compilers().Tx {
+		t.Error("reload changed the previous snapshot")
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+package rego
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+//latencyBuckets are upper bounds of the evaluation time histogram, the last bucket is unbounded
+var latencyBuckets = []time.Duration{
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+//PolicyStats are cumulative counters of a single policy, updated atomically
+type PolicyStats struct {
+	Evals   uint64   `json:"evals"`
+	Errors  uint64   `json:"errors"`
+	Drops   uint64   `json:"drops"`
+	Msgs    uint64   `json:"msgs"`
+	TotalNs uint64   `json:"total_ns"`
+	Buckets []uint64 `json:"buckets"`
+}
+
+//evalStats holds stats of all policies, the map is never modified after creation
+type evalStats map[string]*PolicyStats
+
+func newEvalStats() evalStats {
+	es := make(evalStats)
+	for _, m := range []string{"status", "block", "tx"} {
+		es[m] = &PolicyStats{Buckets: make([]uint64, len(latencyBuckets)+1)}
+	}
+	return es
+}
+
+func (es evalStats) observe(module string, routes []Route, err error, latency time.Duration) {
+	ps, ok := es[module]
+	if !ok {
+		return
+	}
+	atomic.AddUint64(&ps.Evals, 1)
+	atomic.AddUint64(&ps.TotalNs, uint64(latency))
+	b := len(latencyBuckets)
+	for i := range latencyBuckets {
+		if latency <= latencyBuckets[i] {
+			b = i
+			break
+		}
+	}
+	atomic.AddUint64(&ps.Buckets[b], 1)
+	switch {
+	case err != nil:
+		atomic.AddUint64(&ps.Errors, 1)
+	case len(routes) == 0:
+		atomic.AddUint64(&ps.Drops, 1)
+	default:
+		atomic.AddUint64(&ps.Msgs, uint64(len(routes)))
+	}
+}
+
+//Snapshot returns a consistent enough copy of the counters
+func (es evalStats) Snapshot() map[string]PolicyStats {
+	snap := make(map[string]PolicyStats, len(es))
+	for m, ps := range es {
+		c := PolicyStats{
+			Evals:   atomic.LoadUint64(&ps.Evals),
+			Errors:  atomic.LoadUint64(&ps.Errors),
+			Drops:   atomic.LoadUint64(&ps.Drops),
+			Msgs:    atomic.LoadUint64(&ps.Msgs),
+			TotalNs: atomic.LoadUint64(&ps.TotalNs),
+			Buckets: make([]uint64, len(ps.Buckets)),
+		}
+		for i := range ps.Buckets {
+			c.Buckets[i] = atomic.LoadUint64(&ps.Buckets[i])
+		}
+		snap[m] = c
+	}
+	return snap
+}
+
+//sub returns counters accumulated since prev
+func (ps PolicyStats) sub(prev PolicyStats) PolicyStats {
+	d := PolicyStats{
+		Evals:   ps.Evals - prev.Evals,
+		Errors:  ps.Errors - prev.Errors,
+		Drops:   ps.Drops - prev.Drops,
+		Msgs:    ps.Msgs - prev.Msgs,
+		TotalNs: ps.TotalNs - prev.TotalNs,
+		Buckets: make([]uint64, len(ps.Buckets)),
+	}
+	for i := range ps.Buckets {
+		d.Buckets[i] = ps.Buckets[i] - prev.Buckets[i]
+	}
+	return d
+}
+
+//quantile returns the upper bound of the bucket holding the q-th evaluation
+func (ps PolicyStats) quantile(q float64) string {
+	if ps.Evals == 0 {
+		return "-"
+	}
+	want := uint64(q * float64(ps.Evals))
+	var cum uint64
+	for i := range ps.Buckets {
+		cum += ps.Buckets[i]
+		if cum >= want && cum > 0 {
+			if i < len(latencyBuckets) {
+				return "<=" + latencyBuckets[i].String()
+			}
+			return ">" + latencyBuckets[len(latencyBuckets)-1].String()
+		}
+	}
+	return "-"
+}
+
+//logStats prints per policy evaluation stats for each interval
+func (es evalStats) logStats(ctx context.Context, interval time.Duration) {
+	prev := es.Snapshot()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cur := es.Snapshot()
+			for _, m := range []string{"status", "block", "tx"} {
+				d := cur[m].sub(prev[m])
+				if d.Evals == 0 {
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "[STAT][REGO] %s evals:%d err:%d drop:%d msgs:%d avg:%s p50%s p99%s total:%s\n",
+					m, d.Evals, d.Errors, d.Drops, d.Msgs,
+					time.Duration(d.TotalNs/d.Evals), d.quantile(0.5), d.quantile(0.99), time.Duration(d.TotalNs))
+			}
+			prev = cur
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+package rego
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+	es := newEvalStats()
+	es.observe("tx", []Route{{}, {}}, nil, 10*time.Microsecond)
+	es.observe("tx", []Route{}, nil, 3*time.Millisecond)
+	es.observe("tx", nil, errors.New("x"), time.Second)
+	es.observe("other", []Route{{}}, nil, time.Millisecond)
+
+	got := es.Snapshot()["tx"]
+	buckets := make([]uint64, len(latencyBuckets)+1)
+	buckets[0], buckets[6], buckets[len(latencyBuckets)] = 1, 1, 1
+	want := PolicyStats{Evals: 3, Errors: 1, Drops: 1, Msgs: 2,
+		TotalNs: uint64(time.Second + 3*time.Millisecond + 10*time.Microsecond), Buckets: buckets}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if s := es.Snapshot()["block"]; s.Evals != 0 {
+		t.Errorf("block %+v", s)
+	}
+
+	prev := got
+	es.observe("tx", []Route{{}}, nil, 10*time.Microsecond)
+	d := es.Snapshot()["tx"].sub(prev)
+	if d.Evals != 1 || d.Msgs != 1 || d.Buckets[0] != 1 || d.Errors != 0 {
+		t.Errorf("sub %+v", d)
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	ps := PolicyStats{Evals: 100, Buckets: make([]uint64, len(latencyBuckets)+1)}
+	ps.Buckets[1], ps.Buckets[4], ps.Buckets[len(latencyBuckets)] = 60, 39, 1
+	tests := []struct {
+		q    float64
+		want string
+	}{
+		{0.5, "<=100µs"},
+		{0.99, "<=1ms"},
+		{1, ">100ms"},
+	}
+	for _, tt := range tests {
+		if got := ps.quantile(tt.q); got != tt.want {
+			t.Errorf("quantile(%v) = %s, want %s", tt.q, got, tt.want)
+		}
+	}
+	if got := (PolicyStats{}).quantile(0.5); got != "-" {
+		t.Errorf("empty quantile = %s", got)
+	}
+}
+
+func TestRunRecords(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &OpaConfig{Rules: RegoRulesMap{Tx: writeRule(t, dir, "tx.rego", "package tx\ndrop { input.txn.txn.amt == 1 }")}, Workers: 1}
+	if err := CompileCfg(cfg); err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.Compilers()
+	c.log = &decisionLog{ch: make(chan *decisionEntry, 10)}
+	for intra := 0; intra < 3; intra++ {
+		if _, err := c.EvalTx(context.Background(), testTx(intra)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := c.stats.Snapshot()["tx"]; s.Evals != 3 || s.Drops != 1 || s.Msgs != 2 {
+		t.Errorf("stats %+v", s)
+	}
+	if n := len(c.log.ch); n != 3 {
+		t.Errorf("%d log entries, want 3", n)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/algonode/algostreamer/api"
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+//filter is a compiled TxnFilter, nil sets match anything
+type filter struct {
+	sender   map[types.Address]struct{}
+	receiver map[types.Address]struct{}
+	asset    map[uint64]struct{}
+	app      map[uint64]struct{}
+	txType   map[string]struct{}
+	note     []byte
+}
+
+func addrSet(addrs []string) (map[types.Address]struct{}, error) {
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	set := make(map[types.Address]struct{}, len(addrs))
+	for _, a := range addrs {
+		addr, err := types.DecodeAddress(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %s: %s", a, err)
+		}
+		set[addr] = struct{}{}
+	}
+	return set, nil
+}
+
+func idSet(ids []uint64) map[uint64]struct{} {
+	if len(ids) == 0 {
+		return nil
+	}
+	set := make(map[uint64]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
+
+func newFilter(f *api.TxnFilter) (*filter, error) {
+	flt := &filter{}
+	if f == nil {
+		return flt, nil
+	}
+	var err error
+	if flt.sender, err = addrSet(f.Sender); err != nil {
+		return nil, err
+	}
+	if flt.receiver, err = addrSet(f.Receiver); err != nil {
+		return nil, err
+	}
+	flt.asset = idSet(f.Asset)
+	flt.app = idSet(f.App)
+	if len(f.Type) > 0 {
+		flt.txType = make(map[string]struct{}, len(f.Type))
+		for _, t := range f.Type {
+			flt.txType[t] = struct{}{}
+		}
+	}
+	flt.note = f.NotePrefix
+	return flt, nil
+}
+
+func (f *filter) match(txw *algod.TxWrap) bool {
+	tx := &txw.Txn.Txn
+	if f.txType != nil {
+		if _, ok := f.txType[string(tx.Type)]; !ok {
+			return false
+		}
+	}
+	if f.sender != nil {
+		if _, ok := f.sender[tx.Sender]; !ok {
+			return false
+		}
+	}
+	if len(f.note) > 0 && !bytes.HasPrefix(tx.Note, f.note) {
+		return false
+	}
+	if f.receiver == nil && f.asset == nil && f.app == nil {
+		return true
+	}
+	refs := sink.Refs(txw)
+	if f.receiver != nil {
+		if _, ok := f.receiver[refs.Receiver]; !ok {
+			return false
+		}
+	}
+	if f.asset != nil {
+		if _, ok := f.asset[refs.Asset]; !ok {
+			return false
+		}
+	}
+	if f.app != nil {
+		if _, ok := f.app[refs.App]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"testing"
+
+	"github.com/algonode/algostreamer/api"
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+func TestFilterMatch(t *testing.T) {
+	alice, bob := types.Address{1}, types.Address{2}
+	axfer := types.Transaction{Type: types.AssetTransferTx}
+	axfer.Sender, axfer.AssetReceiver, axfer.XferAsset = alice, bob, 31566704
+	axfer.Note = []byte("hello")
+	appl := types.Transaction{Type: types.ApplicationCallTx}
+	appl.Sender, appl.ApplicationID = bob, 10
+	appl.ForeignAssets = []types.AssetIndex{31566704}
+
+	tests := []struct {
+		name   string
+		filter *api.TxnFilter
+		tx     types.Transaction
+		want   bool
+	}{
+		{"no filter", nil, axfer, true},
+		{"empty filter", &api.TxnFilter{}, appl, true},
+		{"sender", &api.TxnFilter{Sender: []string{alice.String()}}, axfer, true},
+		{"other sender", &api.TxnFilter{Sender: []string{alice.String()}}, appl, false},
+		{"any of the values", &api.TxnFilter{Sender: []string{bob.String(), alice.String()}}, appl, true},
+		{"receiver", &api.TxnFilter{Receiver: []string{bob.String()}}, axfer, true},
+		{"asset and type", &api.TxnFilter{Asset: []uint64{31566704}, Type: []string{"axfer"}}, axfer, true},
+		{"every field has to match", &api.TxnFilter{Asset: []uint64{31566704}, Type: []string{"pay"}}, axfer, false},
+		{"foreign assets do not match", &api.TxnFilter{Asset: []uint64{31566704}}, appl, false},
+		{"app", &api.TxnFilter{App: []uint64{10}}, appl, true},
+		{"note prefix", &api.TxnFilter{NotePrefix: []byte("hel")}, axfer, true},
+		{"other note", &api.TxnFilter{NotePrefix: []byte("bye")}, axfer, false},
+		{"note longer than the note", &api.TxnFilter{NotePrefix: []byte("hello!")}, axfer, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newFilter(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			txw := &algod.TxWrap{Txn: &types.SignedTxnInBlock{SignedTxnWithAD: types.SignedTxnWithAD{SignedTxn: types.SignedTxn{Txn: tt.tx}}}}
+			if got := f.match(txw); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterInvalid(t *testing.T) {
+	if _, err := newFilter(&api.TxnFilter{Sender: []string{"nope"}}); err == nil {
+		t.Error("invalid address accepted")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"sync"
+
+	"github.com/algonode/algostreamer/internal/algod"
+)
+
+//liveBlock is a block as seen by the sink, txns are wrapped once for all subscribers
+type liveBlock struct {
+	bw   *algod.BlockWrap
+	once sync.Once
+	txns []*algod.TxWrap
+}
+
+func (lb *liveBlock) round() uint64 {
+	return uint64(lb.bw.Block.Round)
+}
+
+func (lb *liveBlock) wrapped() []*algod.TxWrap {
+	lb.once.Do(func() { lb.txns = algod.WrapTxns(lb.bw) })
+	return lb.txns
+}
+
+type subscriber struct {
+	ch chan *liveBlock
+	//slow is closed when the subscriber falls behind and gets dropped
+	slow chan struct{}
+}
+
+//live keeps the last blocks and fans new ones out to subscribers
+type live struct {
+	mu   sync.Mutex
+	ring []*liveBlock
+	size int
+	subs map[*subscriber]struct{}
+}
+
+func newLive(size int) *live {
+	return &live{size: size, subs: make(map[*subscriber]struct{})}
+}
+
+func (l *live) publish(lb *liveBlock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.ring) == l.size {
+		copy(l.ring, l.ring[1:])
+		l.ring = l.ring[:len(l.ring)-1]
+	}
+	l.ring = append(l.ring, lb)
+	for sub := range l.subs {
+		select {
+		case sub.ch <- lb:
+		default:
+			close(sub.slow)
+			delete(l.subs, sub)
+		}
+	}
+}
+
+//subscribe registers a subscriber and returns the kept blocks,
+//both under the lock so the kept blocks continue exactly where live blocks start
+func (l *live) subscribe(buffer int) (*subscriber, []*liveBlock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sub := &subscriber{ch: make(chan *liveBlock, buffer), slow: make(chan struct{})}
+	l.subs[sub] = struct{}{}
+	ring := make([]*liveBlock, len(l.ring))
+	copy(ring, l.ring)
+	return sub, ring
+}
+
+func (l *live) unsubscribe(sub *subscriber) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.subs, sub)
+}
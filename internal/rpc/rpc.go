@@ -0,0 +1,322 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/algonode/algostreamer/api"
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algonode/algostreamer/internal/utils"
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+	"github.com/algorand/go-algorand-sdk/encoding/msgpack"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultListen = ":8092"
+	defaultRing   = 100
+	defaultBuffer = 1000
+)
+
+type RpcConfig struct {
+	//Listen is the address of the gRPC server
+	Listen string `json:"listen"`
+	//Ring is the number of recent blocks kept in memory,
+	//subscriptions starting before them are replayed from sinks keeping history
+	Ring int `json:"ring"`
+	//Buffer is the number of blocks queued per subscription,
+	//subscribers falling further behind are disconnected
+	Buffer int `json:"buffer"`
+	//TLS enables TLS with cert and key, a ca requires client certificates signed by it
+	TLS *utils.TLSConfig `json:"tls"`
+}
+
+type rpcSink struct {
+	sink.NoCheckpoint
+	api.UnimplementedStreamerServer
+	name string
+	cfg  *RpcConfig
+	live *live
+	hist []sink.History
+	srv  *grpc.Server
+	quit chan struct{}
+}
+
+//errGap stops a history read at the first missing round
+var errGap = errors.New("gap")
+
+func init() {
+	sink.Register("grpc", func() sink.Sink { return &rpcSink{} })
+}
+
+func (s *rpcSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &RpcConfig{}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, s.cfg); err != nil {
+			return fmt.Errorf("[GRPC] invalid config: %s", err)
+		}
+	}
+	if s.cfg.Listen == "" {
+		s.cfg.Listen = defaultListen
+	}
+	if s.cfg.Ring <= 0 {
+		s.cfg.Ring = defaultRing
+	}
+	if s.cfg.Buffer <= 0 {
+		s.cfg.Buffer = defaultBuffer
+	}
+	s.live = newLive(s.cfg.Ring)
+	s.quit = make(chan struct{})
+
+	var opts []grpc.ServerOption
+	if s.cfg.TLS != nil {
+		tc, err := s.cfg.TLS.Load()
+		if err != nil {
+			return fmt.Errorf("[GRPC] %s", err)
+		}
+		if tc.RootCAs != nil {
+			tc.ClientCAs = tc.RootCAs
+			tc.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tc)))
+	}
+	ln, err := net.Listen("tcp", s.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("[GRPC] %s", err)
+	}
+	s.srv = grpc.NewServer(opts...)
+	api.RegisterStreamerServer(s.srv, s)
+	go func() {
+		if err := s.srv.Serve(ln); err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][GRPC][%s] %s\n", s.name, err)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "[INFO][GRPC][%s] Listening on %s\n", s.name, s.cfg.Listen)
+	return nil
+}
+
+//SetHistory receives the sinks subscriptions from past rounds are replayed from
+func (s *rpcSink) SetHistory(h []sink.History) {
+	s.hist = h
+}
+
+func (s *rpcSink) SubscribeBlocks(req *api.SubscribeBlocksRequest, stream api.Streamer_SubscribeBlocksServer) error {
+	return s.subscribe(stream.Context(), req.FromRound, func(lb *liveBlock) error {
+		return stream.Send(blockMsg(lb.bw))
+	})
+}
+
+func (s *rpcSink) SubscribeTxns(req *api.SubscribeTxnsRequest, stream api.Streamer_SubscribeTxnsServer) error {
+	flt, err := newFilter(req.Filter)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return s.subscribe(stream.Context(), req.FromRound, func(lb *liveBlock) error {
+		for _, txw := range lb.wrapped() {
+			if !flt.match(txw) {
+				continue
+			}
+			if err := stream.Send(txnMsg(lb.bw, txw)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//subscribe sends blocks in round order starting at from, or at the next live block if from is 0.
+//Past rounds are read from history sinks first without holding a live subscription,
+//then the live subscription is taken together with the kept blocks and history fills
+//whatever is left between them, so every round is sent exactly once.
+func (s *rpcSink) subscribe(ctx context.Context, from uint64, send func(*liveBlock) error) error {
+	next := from
+	if from > 0 {
+		var err error
+		if next, err = s.replay(ctx, next, 0, send); err != nil {
+			return err
+		}
+	}
+	sub, ring := s.live.subscribe(s.cfg.Buffer)
+	defer s.live.unsubscribe(sub)
+
+	deliver := func(lb *liveBlock) error {
+		round := lb.round()
+		if round < next {
+			return nil
+		}
+		if next > 0 && round > next {
+			var err error
+			if next, err = s.replay(ctx, next, round-1, send); err != nil {
+				return err
+			}
+			if round > next {
+				return status.Errorf(codes.OutOfRange, "rounds %d-%d are not stored by any sink", next, round-1)
+			}
+		}
+		if err := send(lb); err != nil {
+			return err
+		}
+		next = round + 1
+		return nil
+	}
+	if from > 0 {
+		for _, lb := range ring {
+			if err := deliver(lb); err != nil {
+				return err
+			}
+		}
+	}
+	for {
+		select {
+		case lb := <-sub.ch:
+			if err := deliver(lb); err != nil {
+				return err
+			}
+		case <-sub.slow:
+			return status.Error(codes.ResourceExhausted, "slow consumer")
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.quit:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		}
+	}
+}
+
+//replay sends rounds from next up to "to" (0 for all) trying history sinks in turn,
+//a sink missing a round hands over to the next one. Returns the first round not sent.
+func (s *rpcSink) replay(ctx context.Context, next uint64, to uint64, send func(*liveBlock) error) (uint64, error) {
+	for _, h := range s.hist {
+		var serr error
+		err := h.ReadBlocks(ctx, next, to, func(bw *algod.BlockWrap) error {
+			round := uint64(bw.Block.Round)
+			if round < next {
+				return nil
+			}
+			if round > next {
+				return errGap
+			}
+			if serr = send(&liveBlock{bw: bw}); serr != nil {
+				return serr
+			}
+			next = round + 1
+			return nil
+		})
+		if serr != nil {
+			return next, serr
+		}
+		if err := ctx.Err(); err != nil {
+			return next, err
+		}
+		if err != nil && !errors.Is(err, errGap) {
+			//a failing sink is skipped, the next one may have the rounds
+			fmt.Fprintf(os.Stderr, "[WARN][GRPC][%s] %s\n", s.name, err)
+		}
+		if to > 0 && next > to {
+			break
+		}
+	}
+	return next, nil
+}
+
+func blockMsg(bw *algod.BlockWrap) *api.Block {
+	b := &api.Block{
+		Round:     uint64(bw.Block.Round),
+		Timestamp: bw.Block.TimeStamp,
+		TxnCount:  uint32(len(bw.Block.Payset)),
+		GenesisId: bw.Block.GenesisID,
+		Msgpack:   bw.BlockRaw,
+	}
+	//blocks read back from JSON files have no raw form
+	if len(b.Msgpack) == 0 {
+		b.Msgpack = msgpack.Encode(models.BlockResponse{Block: *bw.Block})
+	}
+	if !bw.Proposer.IsZero() {
+		b.Proposer = bw.Proposer.String()
+	}
+	return b
+}
+
+func txnMsg(bw *algod.BlockWrap, txw *algod.TxWrap) *api.Txn {
+	r := sink.Row(bw, txw)
+	t := &api.Txn{
+		Round:     r.Round,
+		Intra:     uint32(r.Intra),
+		Txid:      r.TxId,
+		Type:      r.Type,
+		Sender:    r.Sender,
+		Receiver:  r.Receiver,
+		Amount:    r.Amount,
+		Asset:     r.Asset,
+		App:       r.App,
+		Fee:       r.Fee,
+		Note:      r.Note,
+		Timestamp: r.Ts,
+	}
+	if r.Group != "" {
+		t.Group = txw.Txn.Txn.Group[:]
+	}
+	if j, err := utils.EncodeJson(txw.Txn); err == nil {
+		t.Json = string(j)
+	} else {
+		fmt.Fprintf(os.Stderr, "[!ERR][GRPC] %s\n", err)
+	}
+	return t
+}
+
+//HandleBlock makes every block available to subscribers, filters are applied per subscription
+func (s *rpcSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	s.live.publish(&liveBlock{bw: b.BlockWrap})
+	return nil
+}
+
+func (s *rpcSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	return nil
+}
+
+func (s *rpcSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+//Close ends open streams and waits for them until ctx expires
+func (s *rpcSink) Close(ctx context.Context) error {
+	if s.srv == nil {
+		return nil
+	}
+	close(s.quit)
+	done := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.srv.Stop()
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algorand/go-algorand-sdk/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//history stores the listed rounds
+type history []uint64
+
+func (h history) ReadBlocks(ctx context.Context, from uint64, to uint64, fn func(*algod.BlockWrap) error) error {
+	for _, r := range h {
+		if r < from || (to > 0 && r > to) {
+			continue
+		}
+		if err := fn(block(r)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func block(round uint64) *algod.BlockWrap {
+	return &algod.BlockWrap{Block: &types.Block{BlockHeader: types.BlockHeader{Round: types.Round(round)}}}
+}
+
+func rounds(first, last uint64) []uint64 {
+	var r []uint64
+	for i := first; i <= last; i++ {
+		r = append(r, i)
+	}
+	return r
+}
+
+func TestSubscribeHandover(t *testing.T) {
+	tests := []struct {
+		name string
+		hist []sink.History
+		ring []uint64
+		live []uint64
+		from uint64
+		want []uint64
+		code codes.Code
+	}{
+		{name: "live only", ring: rounds(8, 10), live: rounds(11, 12), want: rounds(11, 12)},
+		{name: "from the ring", ring: rounds(8, 10), live: rounds(11, 12), from: 9, want: rounds(9, 12)},
+		{name: "history overlaps the ring", hist: []sink.History{history(rounds(5, 9))}, ring: rounds(8, 10), live: rounds(11, 12), from: 5, want: rounds(5, 12)},
+		{name: "history fills the gap to the ring", hist: []sink.History{history(rounds(1, 7))}, ring: rounds(8, 10), live: rounds(11, 12), from: 5, want: rounds(5, 12)},
+		{name: "next sink takes over", hist: []sink.History{history(rounds(5, 6)), history(rounds(1, 9))}, ring: rounds(8, 10), live: []uint64{11}, from: 5, want: rounds(5, 11)},
+		{name: "history ahead of the ring", hist: []sink.History{history(rounds(5, 12))}, live: rounds(11, 13), from: 5, want: rounds(5, 13)},
+		{name: "rounds nobody has", hist: []sink.History{history(rounds(5, 6))}, ring: rounds(9, 10), from: 5, want: rounds(5, 6), code: codes.OutOfRange},
+		{name: "no history", ring: rounds(8, 10), from: 3, code: codes.OutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &rpcSink{name: "test", cfg: &RpcConfig{Buffer: 100}, live: newLive(100), hist: tt.hist, quit: make(chan struct{})}
+			for _, r := range tt.ring {
+				s.live.publish(&liveBlock{bw: block(r)})
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			got := make(chan uint64, 100)
+			done := make(chan error, 1)
+			go func() {
+				done <- s.subscribe(ctx, tt.from, func(lb *liveBlock) error {
+					got <- lb.round()
+					return nil
+				})
+			}()
+			//live blocks go out only once the subscription is taken,
+			//a failing subscription may be gone before that
+			var (
+				err      error
+				returned bool
+			)
+		wait:
+			for {
+				s.live.mu.Lock()
+				n := len(s.live.subs)
+				s.live.mu.Unlock()
+				if n > 0 {
+					break
+				}
+				select {
+				case err = <-done:
+					returned = true
+					break wait
+				case <-time.After(time.Millisecond):
+				}
+			}
+			if !returned {
+				for _, r := range tt.live {
+					s.live.publish(&liveBlock{bw: block(r)})
+				}
+			}
+
+			var sent []uint64
+		recv:
+			for !returned || len(got) > 0 {
+				select {
+				case r := <-got:
+					sent = append(sent, r)
+					if tt.code == codes.OK && len(sent) == len(tt.want) {
+						break recv
+					}
+				case err = <-done:
+					returned = true
+				case <-ctx.Done():
+					break recv
+				}
+			}
+			if tt.code != codes.OK {
+				if status.Code(err) != tt.code {
+					t.Errorf("error %v, want %s", err, tt.code)
+				}
+			}
+			if !reflect.DeepEqual(sent, tt.want) {
+				t.Errorf("sent %v, want %v", sent, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlowConsumer(t *testing.T) {
+	s := &rpcSink{name: "test", cfg: &RpcConfig{Buffer: 1}, live: newLive(10), quit: make(chan struct{})}
+	sub, _ := s.live.subscribe(s.cfg.Buffer)
+	for _, r := range rounds(1, 2) {
+		s.live.publish(&liveBlock{bw: block(r)})
+	}
+	select {
+	case <-sub.slow:
+	default:
+		t.Error("subscriber with a full queue was not dropped")
+	}
+	if _, ok := s.live.subs[sub]; ok {
+		t.Error("dropped subscriber is still registered")
+	}
+}
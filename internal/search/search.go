@@ -0,0 +1,378 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algonode/algostreamer/internal/utils"
+)
+
+const (
+	defaultIndex      = "algo-txns"
+	defaultBulk       = 1000
+	defaultTimeout    = 30
+	defaultRetries    = 5
+	defaultBackoff    = 1
+	defaultMaxBackoff = 30
+)
+
+type SearchConfig struct {
+	//URL of the Elasticsearch or OpenSearch cluster
+	URL    string `json:"url"`
+	User   string `json:"user"`
+	Pass   string `json:"pass"`
+	ApiKey string `json:"apiKey"`
+	//Index is the index name prefix and the template name
+	Index string `json:"index"`
+	//Checkpoint is the index of the last round document of every sink, defaults to <index>.checkpoint
+	Checkpoint string `json:"checkpoint"`
+	//Rounds switches from daily indexes to one index per round range of this size
+	Rounds uint64 `json:"rounds"`
+	//Template creates or updates the index template on start
+	Template bool `json:"template"`
+	Shards   int  `json:"shards"`
+	Replicas int  `json:"replicas"`
+	//Bulk is the number of documents per bulk request
+	Bulk int `json:"bulk"`
+	//Timeout is in seconds per bulk request
+	Timeout int `json:"timeout"`
+	//Retries, Backoff and MaxBackoff control retries of failed documents, in seconds
+	Retries    int              `json:"retries"`
+	Backoff    int              `json:"backoff"`
+	MaxBackoff int              `json:"maxBackoff"`
+	TLS        *utils.TLSConfig `json:"tls"`
+}
+
+type searchSink struct {
+	name string
+	cfg  *SearchConfig
+	hc   *http.Client
+}
+
+//bulkDoc is an action line and the document of a bulk request
+type bulkDoc struct {
+	index string
+	id    string
+	body  []byte
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Id     string          `json:"_id"`
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+func init() {
+	sink.Register("elastic", func() sink.Sink { return &searchSink{} })
+	sink.Register("opensearch", func() sink.Sink { return &searchSink{} })
+}
+
+func (s *searchSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &SearchConfig{Template: true, Shards: 1, Replicas: 1}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, s.cfg); err != nil {
+			return fmt.Errorf("[SEARCH] invalid config: %s", err)
+		}
+	}
+	if s.cfg.URL == "" {
+		return fmt.Errorf("[SEARCH] url is missing")
+	}
+	s.cfg.URL = strings.TrimRight(s.cfg.URL, "/")
+	if s.cfg.Index == "" {
+		s.cfg.Index = defaultIndex
+	}
+	if s.cfg.Checkpoint == "" {
+		s.cfg.Checkpoint = s.cfg.Index + ".checkpoint"
+	}
+	if s.cfg.Bulk <= 0 {
+		s.cfg.Bulk = defaultBulk
+	}
+	if s.cfg.Timeout <= 0 {
+		s.cfg.Timeout = defaultTimeout
+	}
+	if s.cfg.Retries <= 0 {
+		s.cfg.Retries = defaultRetries
+	}
+	if s.cfg.Backoff <= 0 {
+		s.cfg.Backoff = defaultBackoff
+	}
+	if s.cfg.MaxBackoff <= 0 {
+		s.cfg.MaxBackoff = defaultMaxBackoff
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if s.cfg.TLS != nil {
+		tc, err := s.cfg.TLS.Load()
+		if err != nil {
+			return fmt.Errorf("[SEARCH] %s", err)
+		}
+		tr.TLSClientConfig = tc
+	}
+	s.hc = &http.Client{Transport: tr}
+	if s.cfg.Template {
+		tpl := fmt.Sprintf(indexTemplate, s.cfg.Index+"-*", s.cfg.Shards, s.cfg.Replicas)
+		if _, err := s.do(ctx, http.MethodPut, "/_index_template/"+s.cfg.Index, "application/json", []byte(tpl)); err != nil {
+			return fmt.Errorf("[SEARCH] index template: %s", err)
+		}
+	}
+	return nil
+}
+
+//index picks a daily index by block time or a fixed round range index
+func (s *searchSink) index(round uint64, ts int64) string {
+	if s.cfg.Rounds > 0 {
+		return fmt.Sprintf("%s-%012d", s.cfg.Index, round/s.cfg.Rounds*s.cfg.Rounds)
+	}
+	return s.cfg.Index + "-" + time.Unix(ts, 0).UTC().Format("2006.01.02")
+}
+
+//document adds the flattened txn fields the template maps to the encoded txn
+func document(b *sink.Block, t *sink.Tx) ([]byte, error) {
+	body, err := t.Encode(t.TxWrap)
+	if err != nil {
+		return nil, err
+	}
+	doc := make(map[string]interface{})
+	dec := json.NewDecoder(bytes.NewReader(body))
+	//keep uint64 amounts exact
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		//rules may transform the txn into anything
+		doc = map[string]interface{}{"tx": json.RawMessage(body)}
+	}
+	//rule payloads cannot drop the fields that locate the txn
+	r := sink.Row(b.BlockWrap, t.TxWrap)
+	doc["round"] = r.Round
+	doc["intra"] = r.Intra
+	doc["txid"] = r.TxId
+	doc["ts"] = r.Ts
+	doc["type"] = r.Type
+	doc["sender"] = r.Sender
+	if r.Receiver != "" {
+		doc["receiver"] = r.Receiver
+	}
+	doc["amount"] = r.Amount
+	if r.Asset > 0 {
+		doc["asset"] = r.Asset
+	}
+	if r.App > 0 {
+		doc["app"] = r.App
+	}
+	doc["fee"] = r.Fee
+	if r.Group != "" {
+		doc["group"] = r.Group
+	}
+	if len(r.Note) > 0 && utf8.Valid(r.Note) {
+		doc["note_text"] = string(r.Note)
+	}
+	return json.Marshal(doc)
+}
+
+//HandleBlock bulk indexes the txns of a block with the txid as document id,
+//so a retried block overwrites the same documents.
+//The checkpoint document goes last in the last bulk request, also for blocks without txns.
+func (s *searchSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	start := time.Now()
+	round := uint64(b.Block.Round)
+	index := s.index(round, b.Block.TimeStamp)
+	docs := make([]bulkDoc, 0, len(b.Txns)+1)
+	for _, t := range b.Txns {
+		body, err := document(b, t)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][SEARCH] %s\n", err)
+			continue
+		}
+		docs = append(docs, bulkDoc{index: index, id: t.TxId, body: body})
+	}
+	txns := len(docs)
+	cp, _ := json.Marshal(map[string]interface{}{"round": round, "updated": time.Now().UTC()})
+	docs = append(docs, bulkDoc{index: s.cfg.Checkpoint, id: s.name, body: cp})
+	for i := 0; i < len(docs); i += s.cfg.Bulk {
+		end := i + s.cfg.Bulk
+		if end > len(docs) {
+			end = len(docs)
+		}
+		if err := s.bulk(ctx, docs[i:end]); err != nil {
+			return fmt.Errorf("[SEARCH] block %d: %s", round, err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "[INFO][SEARCH][%s] Block %d indexed into %s in %s (%d txn)\n", s.name, round, index, time.Since(start), txns)
+	return nil
+}
+
+//bulk sends the documents and retries only the ones that failed with a retryable status.
+//Documents rejected for good, e.g. on mapping errors, are logged and skipped.
+func (s *searchSink) bulk(ctx context.Context, docs []bulkDoc) error {
+	pending := docs
+	return utils.Backoff(ctx, func(actx context.Context) error {
+		var buf bytes.Buffer
+		for _, d := range pending {
+			action, _ := json.Marshal(map[string]interface{}{"index": map[string]string{"_index": d.index, "_id": d.id}})
+			buf.Write(action)
+			buf.WriteByte('\n')
+			buf.Write(d.body)
+			buf.WriteByte('\n')
+		}
+		resp, err := s.do(actx, http.MethodPost, "/_bulk", "application/x-ndjson", buf.Bytes())
+		if err != nil {
+			return err
+		}
+		var br bulkResponse
+		if err := json.Unmarshal(resp, &br); err != nil {
+			return fmt.Errorf("invalid bulk response: %s", err)
+		}
+		if !br.Errors {
+			return nil
+		}
+		if len(br.Items) != len(pending) {
+			return fmt.Errorf("bulk response has %d items for %d documents", len(br.Items), len(pending))
+		}
+		retry := pending[:0:0]
+		for i, item := range br.Items {
+			for _, res := range item {
+				switch {
+				case res.Status < 300:
+				case res.Status == http.StatusTooManyRequests || res.Status >= 500:
+					retry = append(retry, pending[i])
+				default:
+					fmt.Fprintf(os.Stderr, "[!ERR][SEARCH][%s] document %s rejected: %s\n", s.name, pending[i].id, res.Error)
+				}
+			}
+		}
+		pending = retry
+		if len(pending) > 0 {
+			return fmt.Errorf("%d documents failed", len(pending))
+		}
+		return nil
+	}, time.Duration(s.cfg.Timeout)*time.Second, time.Duration(s.cfg.Backoff)*time.Second, time.Duration(s.cfg.MaxBackoff)*time.Second, utils.MaxTries(s.cfg.Retries))
+}
+
+//do sends a request and returns the response body, 429 and 5xx errors are retryable
+func (s *searchSink) do(ctx context.Context, method string, path string, ctype string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.cfg.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, utils.Permanent{Err: err}
+	}
+	req.Header.Set("Content-Type", ctype)
+	req.Header.Set("User-Agent", "algostreamer")
+	switch {
+	case s.cfg.ApiKey != "":
+		req.Header.Set("Authorization", "ApiKey "+s.cfg.ApiKey)
+	case s.cfg.User != "":
+		req.SetBasicAuth(s.cfg.User, s.cfg.Pass)
+	}
+	resp, err := s.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	rb, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return rb, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, fmt.Errorf("http status %d: %s", resp.StatusCode, truncate(rb))
+	default:
+		return nil, utils.Permanent{Err: fmt.Errorf("http status %d: %s", resp.StatusCode, truncate(rb))}
+	}
+}
+
+func truncate(b []byte) []byte {
+	if len(b) > 512 {
+		return b[:512]
+	}
+	return b
+}
+
+func (s *searchSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	return nil
+}
+
+func (s *searchSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *searchSink) Close(ctx context.Context) error {
+	s.hc.CloseIdleConnections()
+	return nil
+}
+
+//LastCommittedRound reads the checkpoint document of the sink.
+//The streamer resumes at that round, so a partially indexed block is indexed again.
+//Indexes written before checkpoint documents were added resume from the highest indexed txn round.
+func (s *searchSink) LastCommittedRound(ctx context.Context) (uint64, error) {
+	q, _ := json.Marshal(map[string]interface{}{"query": map[string]interface{}{"ids": map[string]interface{}{"values": []string{s.name}}}})
+	resp, err := s.do(ctx, http.MethodPost, "/"+s.cfg.Checkpoint+"/_search?ignore_unavailable=true&allow_no_indices=true", "application/json", q)
+	if err != nil {
+		return 0, fmt.Errorf("[SEARCH] error getting checkpoint %v", err)
+	}
+	var sr struct {
+		Hits struct {
+			Hits []struct {
+				Source struct {
+					Round uint64 `json:"round"`
+				} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(resp, &sr); err != nil {
+		return 0, fmt.Errorf("[SEARCH] error getting checkpoint %v", err)
+	}
+	if len(sr.Hits.Hits) == 0 {
+		return s.lastTxnRound(ctx)
+	}
+	return sr.Hits.Hits[0].Source.Round, nil
+}
+
+//lastTxnRound asks for the highest indexed txn round
+func (s *searchSink) lastTxnRound(ctx context.Context) (uint64, error) {
+	q := []byte(`{"size":0,"aggs":{"last":{"max":{"field":"round"}}}}`)
+	resp, err := s.do(ctx, http.MethodPost, "/"+s.cfg.Index+"-*/_search?ignore_unavailable=true&allow_no_indices=true", "application/json", q)
+	if err != nil {
+		return 0, fmt.Errorf("[SEARCH] error getting last round %v", err)
+	}
+	var sr struct {
+		Aggregations struct {
+			Last struct {
+				Value *float64 `json:"value"`
+			} `json:"last"`
+		} `json:"aggregations"`
+	}
+	if err := json.Unmarshal(resp, &sr); err != nil {
+		return 0, fmt.Errorf("[SEARCH] error getting last round %v", err)
+	}
+	if sr.Aggregations.Last.Value == nil {
+		return 0, sink.ErrNoCheckpoint
+	}
+	return uint64(*sr.Aggregations.Last.Value), nil
+}
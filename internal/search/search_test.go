@@ -0,0 +1,172 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package search
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+//fakeSearch keeps bulk indexed documents by index and id and answers the searches the sink sends
+type fakeSearch struct {
+	mu   sync.Mutex
+	docs map[string]map[string]map[string]interface{}
+	//bulks lists the indexes of every bulk request
+	bulks [][]string
+}
+
+func (f *fakeSearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path == "/_bulk":
+		var (
+			items   []interface{}
+			indexes []string
+		)
+		sc := bufio.NewScanner(r.Body)
+		sc.Buffer(nil, 1<<20)
+		for sc.Scan() {
+			var action struct {
+				Index struct {
+					Index string `json:"_index"`
+					Id    string `json:"_id"`
+				} `json:"index"`
+			}
+			json.Unmarshal(sc.Bytes(), &action)
+			sc.Scan()
+			var doc map[string]interface{}
+			json.Unmarshal(sc.Bytes(), &doc)
+			idx := action.Index.Index
+			if f.docs[idx] == nil {
+				f.docs[idx] = make(map[string]map[string]interface{})
+			}
+			f.docs[idx][action.Index.Id] = doc
+			indexes = append(indexes, idx)
+			items = append(items, map[string]interface{}{"index": map[string]interface{}{"_id": action.Index.Id, "status": 201}})
+		}
+		f.bulks = append(f.bulks, indexes)
+		json.NewEncoder(w).Encode(map[string]interface{}{"errors": false, "items": items})
+	case strings.HasSuffix(r.URL.Path, "/_search"):
+		var q struct {
+			Query struct {
+				Ids struct {
+					Values []string `json:"values"`
+				} `json:"ids"`
+			} `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&q)
+		idx := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/_search")
+		if len(q.Query.Ids.Values) > 0 {
+			hits := []interface{}{}
+			if doc, ok := f.docs[idx][q.Query.Ids.Values[0]]; ok {
+				hits = append(hits, map[string]interface{}{"_source": doc})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"hits": map[string]interface{}{"hits": hits}})
+			return
+		}
+		var max interface{}
+		for name, docs := range f.docs {
+			if !strings.HasPrefix(name, strings.TrimSuffix(idx, "*")) {
+				continue
+			}
+			for _, doc := range docs {
+				if r, ok := doc["round"].(float64); ok && (max == nil || r > max.(float64)) {
+					max = r
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"aggregations": map[string]interface{}{"last": map[string]interface{}{"value": max}}})
+	default:
+		w.Write([]byte("{}"))
+	}
+}
+
+func testSink(t *testing.T) (*searchSink, *fakeSearch) {
+	f := &fakeSearch{docs: make(map[string]map[string]map[string]interface{})}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	cfg, _ := json.Marshal(&SearchConfig{URL: srv.URL, Rounds: 1000})
+	s := &searchSink{}
+	if err := s.Init(context.Background(), "test", cfg); err != nil {
+		t.Fatal(err)
+	}
+	return s, f
+}
+
+func testBlock(round uint64, txns int) *sink.Block {
+	bw := &algod.BlockWrap{Block: &types.Block{BlockHeader: types.BlockHeader{Round: types.Round(round), TimeStamp: 1660000000}}}
+	b := &sink.Block{BlockWrap: bw}
+	for i := 0; i < txns; i++ {
+		tx := types.Transaction{Type: types.PaymentTx}
+		tx.Sender = types.Address{1}
+		b.Txns = append(b.Txns, &sink.Tx{TxWrap: &algod.TxWrap{
+			TxId:  fmt.Sprintf("T%d-%d", round, i),
+			Txn:   &types.SignedTxnInBlock{SignedTxnWithAD: types.SignedTxnWithAD{SignedTxn: types.SignedTxn{Txn: tx}}},
+			Round: round,
+			Intra: i,
+		}})
+	}
+	return b
+}
+
+func TestCheckpoint(t *testing.T) {
+	s, f := testSink(t)
+	ctx := context.Background()
+	if _, err := s.LastCommittedRound(ctx); !errors.Is(err, sink.ErrNoCheckpoint) {
+		t.Fatalf("empty indexes: %v", err)
+	}
+
+	if err := s.HandleBlock(ctx, testBlock(5, 2)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"algo-txns-000000000000", "algo-txns-000000000000", "algo-txns.checkpoint"}
+	if len(f.bulks) != 1 || strings.Join(f.bulks[0], ",") != strings.Join(want, ",") {
+		t.Errorf("bulk requests %v, want %v", f.bulks, want)
+	}
+	if last, err := s.LastCommittedRound(ctx); err != nil || last != 5 {
+		t.Errorf("LastCommittedRound = %d, %v, want 5", last, err)
+	}
+
+	//a block without txns still moves the checkpoint
+	if err := s.HandleBlock(ctx, testBlock(6, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if last, err := s.LastCommittedRound(ctx); err != nil || last != 6 {
+		t.Errorf("LastCommittedRound = %d, %v, want 6", last, err)
+	}
+}
+
+func TestCheckpointFallback(t *testing.T) {
+	s, f := testSink(t)
+	//txns indexed before checkpoint documents
+	f.docs["algo-txns-000000000000"] = map[string]map[string]interface{}{"A": {"round": float64(7)}, "B": {"round": float64(3)}}
+	if last, err := s.LastCommittedRound(context.Background()); err != nil || last != 7 {
+		t.Errorf("LastCommittedRound = %d, %v, want 7", last, err)
+	}
+}
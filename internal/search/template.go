@@ -0,0 +1,50 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package search
+
+//indexTemplate applies to every index of the sink, %s is the index pattern.
+//The raw txn is kept in _source only, searches go through the flattened fields.
+//amount is indexed as double as OpenSearch has no unsigned_long, _source keeps the exact value.
+const indexTemplate = `{
+  "index_patterns": ["%s"],
+  "template": {
+    "settings": {
+      "number_of_shards": %d,
+      "number_of_replicas": %d,
+      "refresh_interval": "5s"
+    },
+    "mappings": {
+      "dynamic": false,
+      "properties": {
+        "txid":      { "type": "keyword" },
+        "round":     { "type": "long" },
+        "intra":     { "type": "integer" },
+        "xtx-v2":    { "type": "keyword" },
+        "ts":        { "type": "date", "format": "epoch_second" },
+        "type":      { "type": "keyword" },
+        "sender":    { "type": "keyword" },
+        "receiver":  { "type": "keyword" },
+        "amount":    { "type": "double" },
+        "asset":     { "type": "long" },
+        "app":       { "type": "long" },
+        "fee":       { "type": "long" },
+        "group":     { "type": "keyword" },
+        "note_text": { "type": "text", "fields": { "raw": { "type": "keyword", "ignore_above": 256 } } },
+        "txn":       { "type": "object", "enabled": false }
+      }
+    }
+  }
+}`
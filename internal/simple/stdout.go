@@ -17,38 +17,52 @@ package simple
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
 
-	"github.com/algonode/algostreamer/internal/algod"
-	"github.com/algorand/go-algorand/protocol"
-
-	"github.com/algorand/go-codec/codec"
+	"github.com/algonode/algostreamer/internal/sink"
 )
 
-func handleBlockStdOut(b *algod.BlockWrap) error {
-	var output []byte
-	enc := codec.NewEncoderBytes(&output, protocol.JSONStrictHandle)
-	err := enc.Encode(b)
-	if err != nil {
-		return err
+func handleBlockStdOut(b *sink.Block) error {
+	for i := range b.Msgs {
+		output, err := b.Msgs[i].Encode(b.Payload())
+		if err != nil {
+			//retrying will not help
+			fmt.Fprintf(os.Stderr, "[!ERR][STDOUT] %s\n", err)
+			continue
+		}
+		fmt.Println(string(output))
 	}
-	fmt.Println(string(output))
 	return nil
 }
 
-func SimplePusher(ctx context.Context, blocks chan *algod.BlockWrap, status chan *algod.Status) error {
+type stdoutSink struct {
+	sink.NoCheckpoint
+}
+
+func init() {
+	sink.Register("stdout", func() sink.Sink { return &stdoutSink{} })
+}
 
-	go func() {
-		for {
-			select {
-			case <-status:
-				//noop
-			case b := <-blocks:
-				handleBlockStdOut(b)
-			case <-ctx.Done():
-			}
+func (s *stdoutSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	return nil
+}
 
-		}
-	}()
+func (s *stdoutSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	return handleBlockStdOut(b)
+}
+
+func (s *stdoutSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	//noop
+	return nil
+}
+
+func (s *stdoutSink) Flush(ctx context.Context) error {
+	//fmt.Println is unbuffered
+	return nil
+}
+
+func (s *stdoutSink) Close(ctx context.Context) error {
 	return nil
 }
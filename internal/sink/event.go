@@ -0,0 +1,94 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/rego"
+	"github.com/algonode/algostreamer/internal/utils"
+)
+
+//Msg is a single message a sink should emit for an event
+type Msg struct {
+	//Topic overrides the sink default destination when not empty
+	Topic string
+	//Payload replaces the event body when not nil
+	Payload interface{}
+}
+
+//Encode returns JSON of the rule supplied payload or of the original event
+func (m *Msg) Encode(orig interface{}) ([]byte, error) {
+	if m.Payload == nil {
+		return utils.EncodeJson(orig)
+	}
+	return json.Marshal(m.Payload)
+}
+
+//Block is a block with messages routed to a single sink.
+//Every sink gets every block, even with nothing to emit, so it can checkpoint the round.
+type Block struct {
+	*algod.BlockWrap
+	//Msgs lists block messages, empty when the block was filtered out
+	Msgs []Msg
+	//Txns lists txn messages in intra order
+	Txns []*Tx
+}
+
+//Payload is the default body of block messages on every sink -
+//the JSON block wrapper with block, src and ts fields that block rules get as input
+func (b *Block) Payload() interface{} {
+	return b.BlockWrap
+}
+
+//Tx is a single txn message
+type Tx struct {
+	*algod.TxWrap
+	Msg
+}
+
+//Status is a node status update with messages routed to a single sink
+type Status struct {
+	*algod.Status
+	Msgs []Msg
+}
+
+//checkRoutes warns once about routes to sinks that are not configured
+func checkRoutes(routes []rego.Route, known map[string]bool) {
+	for i := range routes {
+		name := routes[i].Sink
+		if name == "" || known[name] {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "[WARN][SINK] rules route to unknown sink %s\n", name)
+		//do not repeat the warning
+		known[name] = true
+	}
+}
+
+//msgsFor picks routes matching the sink name
+func msgsFor(routes []rego.Route, name string) []Msg {
+	msgs := make([]Msg, 0, len(routes))
+	for i := range routes {
+		if routes[i].Sink == "" || routes[i].Sink == name {
+			msgs = append(msgs, Msg{Topic: routes[i].Topic, Payload: routes[i].Payload})
+		}
+	}
+	return msgs
+}
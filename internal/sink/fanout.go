@@ -0,0 +1,164 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/rego"
+)
+
+const (
+	sinkQueue     = 100
+	flushTimeout  = time.Second * 10
+	blockRetryDly = time.Second
+)
+
+type sinkRunner struct {
+	name   string
+	s      Sink
+	blocks chan *Block
+	status chan *Status
+}
+
+//FanOut runs OPA rules on every block, txn and status update and sends
+//the resulting messages to the sinks they are routed to.
+//Each sink consumes from its own queue so a slow sink only stalls the stream
+//once its queue is full. The returned WaitGroup is done when all sinks
+//are flushed and closed after ctx is cancelled.
+func FanOut(ctx context.Context, sinks map[string]Sink, opa *rego.OpaConfig, blocks chan *algod.BlockWrap, status chan *algod.Status) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	runners := make([]*sinkRunner, 0, len(sinks))
+	for name, s := range sinks {
+		r := &sinkRunner{
+			name:   name,
+			s:      s,
+			blocks: make(chan *Block, sinkQueue),
+			status: make(chan *Status, sinkQueue),
+		}
+		if qa, ok := s.(QueueAware); ok {
+			qa.SetQueueLen(func() int { return len(r.blocks) })
+		}
+		runners = append(runners, r)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.run(ctx)
+		}()
+	}
+
+	known := make(map[string]bool, len(sinks))
+	for name := range sinks {
+		known[name] = true
+	}
+
+	go func() {
+		for {
+			select {
+			case s := <-status:
+				routes := evalOrDrop(opa.Compilers().EvalStatus(ctx, s))
+				checkRoutes(routes, known)
+				for _, r := range runners {
+					//status updates are periodic, drop them for sinks that lag behind
+					select {
+					case r.status <- &Status{Status: s, Msgs: msgsFor(routes, r.name)}:
+					default:
+					}
+				}
+			case b := <-blocks:
+				//same rules for the block and all its txns even if they get reloaded meanwhile
+				rules := opa.Compilers()
+				routes := evalOrDrop(rules.EvalBlock(ctx, b))
+				checkRoutes(routes, known)
+				txns := algod.WrapTxns(b)
+				//evaluated in parallel, results keep intra order
+				txRoutes, txErrs := rules.EvalTxns(ctx, txns)
+				for i := range txns {
+					txRoutes[i] = evalOrDrop(txRoutes[i], txErrs[i])
+					checkRoutes(txRoutes[i], known)
+				}
+				for _, r := range runners {
+					sb := &Block{BlockWrap: b, Msgs: msgsFor(routes, r.name)}
+					for i := range txns {
+						for _, m := range msgsFor(txRoutes[i], r.name) {
+							sb.Txns = append(sb.Txns, &Tx{TxWrap: txns[i], Msg: m})
+						}
+					}
+					select {
+					case r.blocks <- sb:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return &wg
+}
+
+//evalOrDrop drops events that rules failed to evaluate rather than leak them to the wrong sink
+func evalOrDrop(routes []rego.Route, err error) []rego.Route {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][SINK] %s\n", err)
+		return nil
+	}
+	return routes
+}
+
+func (r *sinkRunner) run(ctx context.Context) {
+	defer r.shutdown()
+	for {
+		select {
+		case s := <-r.status:
+			//status updates are best effort, sinks log their own errors
+			r.s.HandleStatus(ctx, s)
+		case b := <-r.blocks:
+			for {
+				err := r.s.HandleBlock(ctx, b)
+				if err == nil {
+					break
+				}
+				fmt.Fprintf(os.Stderr, "[!ERR][SINK][%s] block %d: %s\n", r.name, uint64(b.Block.Round), err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(blockRetryDly):
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *sinkRunner) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	if err := r.s.Flush(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][SINK][%s] flush: %s\n", r.name, err)
+	}
+	if err := r.s.Close(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][SINK][%s] close: %s\n", r.name, err)
+	}
+}
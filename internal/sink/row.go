@@ -0,0 +1,74 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package sink
+
+import (
+	"encoding/base64"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+//TxRow is a txn flattened to the columns SQL and analytics sinks store
+type TxRow struct {
+	Round    uint64
+	Intra    int
+	TxId     string
+	Type     string
+	Sender   string
+	Receiver string
+	//Amount is microalgos for payments and base units for asset transfers
+	Amount uint64
+	Asset  uint64
+	App    uint64
+	Fee    uint64
+	Note   []byte
+	//Group is the base64 group id, empty for single txns
+	Group string
+	//Ts is the block timestamp in unix seconds
+	Ts int64
+}
+
+//Row flattens a txn of the block
+func Row(b *algod.BlockWrap, txw *algod.TxWrap) TxRow {
+	tx := &txw.Txn.Txn
+	refs := Refs(txw)
+	r := TxRow{
+		Round:  txw.Round,
+		Intra:  txw.Intra,
+		TxId:   txw.TxId,
+		Type:   string(tx.Type),
+		Sender: tx.Sender.String(),
+		Asset:  refs.Asset,
+		App:    refs.App,
+		Fee:    uint64(tx.Fee),
+		Note:   tx.Note,
+		Ts:     b.Block.TimeStamp,
+	}
+	if !refs.Receiver.IsZero() {
+		r.Receiver = refs.Receiver.String()
+	}
+	switch tx.Type {
+	case types.PaymentTx:
+		r.Amount = uint64(tx.Amount)
+	case types.AssetTransferTx:
+		r.Amount = tx.AssetAmount
+	}
+	if tx.Group != (types.Digest{}) {
+		r.Group = base64.StdEncoding.EncodeToString(tx.Group[:])
+	}
+	return r
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package sink
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+func TestRow(t *testing.T) {
+	bw := &algod.BlockWrap{Block: &types.Block{BlockHeader: types.BlockHeader{Round: 10, TimeStamp: 1660000000}}}
+	pay := types.Transaction{Type: types.PaymentTx}
+	pay.Sender, pay.Receiver, pay.Amount, pay.Fee = alice, bob, 5, 1000
+	pay.Note = []byte("hi")
+	axfer := types.Transaction{Type: types.AssetTransferTx}
+	axfer.Sender, axfer.AssetReceiver, axfer.XferAsset, axfer.AssetAmount = alice, bob, 7, 42
+	axfer.Group = types.Digest{1}
+	appl := types.Transaction{Type: types.ApplicationCallTx}
+	appl.Sender, appl.ApplicationID = alice, 10
+
+	base := TxRow{Round: 10, Intra: 2, TxId: "TXID", Sender: alice.String(), Ts: 1660000000}
+	tests := []struct {
+		name string
+		tx   types.Transaction
+		want func(r *TxRow)
+	}{
+		{"pay", pay, func(r *TxRow) {
+			r.Type, r.Receiver, r.Amount, r.Fee, r.Note = "pay", bob.String(), 5, 1000, []byte("hi")
+		}},
+		{"axfer amount is in base units", axfer, func(r *TxRow) {
+			r.Type, r.Receiver, r.Amount, r.Asset, r.Group = "axfer", bob.String(), 42, 7, "AQAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+		}},
+		{"appl", appl, func(r *TxRow) {
+			r.Type, r.App = "appl", 10
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := base
+			tt.want(&want)
+			if got := Row(bw, wrap(tt.tx)); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/algonode/algostreamer/internal/algod"
+)
+
+//ErrNoCheckpoint is returned by sinks that do not keep track of committed rounds
+var ErrNoCheckpoint = errors.New("sink does not keep a checkpoint")
+
+//Sink is a destination for blocks, txns and node status updates.
+//Each configured sink gets its own goroutine so methods are never called concurrently.
+//Events are already routed by OPA rules, sinks only emit the messages they are given.
+type Sink interface {
+	//Init parses the sink's section of the "sinks" config and connects to the backend
+	Init(ctx context.Context, name string, cfg json.RawMessage) error
+	//HandleBlock commits a block, returning an error makes the block retried
+	HandleBlock(ctx context.Context, b *Block) error
+	//HandleStatus forwards a node status update
+	HandleStatus(ctx context.Context, s *Status) error
+	//Flush pushes out anything the sink buffers internally
+	Flush(ctx context.Context) error
+	//Close releases backend connections
+	Close(ctx context.Context) error
+	//LastCommittedRound returns the last round safely stored by the sink or ErrNoCheckpoint
+	LastCommittedRound(ctx context.Context) (uint64, error)
+}
+
+//NoCheckpoint is embedded by sinks that keep no history, like brokers, queues and servers.
+//The stream resumes from the sinks that do.
+type NoCheckpoint struct{}
+
+//LastCommittedRound always returns ErrNoCheckpoint
+func (NoCheckpoint) LastCommittedRound(ctx context.Context) (uint64, error) {
+	return 0, ErrNoCheckpoint
+}
+
+//QueueAware is implemented by sinks that want to report their backlog
+type QueueAware interface {
+	//SetQueueLen hands the sink a function returning the number of blocks waiting for it
+	SetQueueLen(qlen func() int)
+}
+
+//History is implemented by sinks that can read stored blocks back
+type History interface {
+	//ReadBlocks calls fn for stored blocks from round "from" up to round "to" (0 for no limit) in round order.
+	//Rounds the sink does not have are skipped, an error from fn stops reading.
+	ReadBlocks(ctx context.Context, from uint64, to uint64, fn func(*algod.BlockWrap) error) error
+}
+
+//HistoryAware is implemented by sinks that replay blocks stored by other sinks
+type HistoryAware interface {
+	//SetHistory hands the sink every configured sink implementing History
+	SetHistory(h []History)
+}
+
+//Factory makes a new, uninitialized sink instance
+type Factory func() Sink
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]Factory)
+)
+
+//Register makes a sink type available under the given name.
+//It is meant to be called from the init function of the sink package.
+func Register(kind string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	kind = strings.ToLower(kind)
+	if _, dup := registry[kind]; dup {
+		panic("sink: Register called twice for " + kind)
+	}
+	registry[kind] = f
+}
+
+//Kinds returns sorted names of all registered sink types
+func Kinds() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	kinds := make([]string, 0, len(registry))
+	for k := range registry {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+//Kind returns the sink type for a config key.
+//Keys are either a plain type ("redis") or a type with a label ("webhook:partner1")
+//so that the same sink type can be configured more than once.
+func Kind(name string) string {
+	return strings.ToLower(strings.SplitN(name, ":", 2)[0])
+}
+
+//New creates and initializes a sink for the config key
+func New(ctx context.Context, name string, cfg json.RawMessage) (Sink, error) {
+	registryMu.Lock()
+	f, ok := registry[Kind(name)]
+	registryMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("[SINK] unknown sink type %s, available: %s", Kind(name), strings.Join(Kinds(), ","))
+	}
+	s := f()
+	if err := s.Init(ctx, name, cfg); err != nil {
+		return nil, fmt.Errorf("[SINK][%s] %s", name, err)
+	}
+	return s, nil
+}
+
+//NewAll initializes every sink from the "sinks" config section
+func NewAll(ctx context.Context, cfg map[string]json.RawMessage) (map[string]Sink, error) {
+	sinks := make(map[string]Sink, len(cfg))
+	for name, scfg := range cfg {
+		s, err := New(ctx, name, scfg)
+		if err != nil {
+			for _, s := range sinks {
+				s.Close(ctx)
+			}
+			return nil, err
+		}
+		sinks[name] = s
+	}
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("[SINK] configure at least one sink")
+	}
+	names := make([]string, 0, len(sinks))
+	for name := range sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var hist []History
+	for _, name := range names {
+		if h, ok := sinks[name].(History); ok {
+			hist = append(hist, h)
+		}
+	}
+	for _, s := range sinks {
+		if ha, ok := s.(HistoryAware); ok {
+			ha.SetHistory(hist)
+		}
+	}
+	return sinks, nil
+}
+
+//LastCommittedRound returns the lowest checkpoint across all sinks that keep one
+func LastCommittedRound(ctx context.Context, sinks map[string]Sink) (uint64, error) {
+	var (
+		min   uint64
+		found bool
+	)
+	for name, s := range sinks {
+		r, err := s.LastCommittedRound(ctx)
+		if err != nil {
+			if !errors.Is(err, ErrNoCheckpoint) {
+				return 0, fmt.Errorf("[SINK] %s: %w", name, err)
+			}
+			continue
+		}
+		if !found || r < min {
+			min = r
+			found = true
+		}
+	}
+	if !found {
+		return 0, ErrNoCheckpoint
+	}
+	return min, nil
+}
@@ -0,0 +1,76 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package sink
+
+import (
+	"strings"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+//TopicKey is a single txn subscription key, ACC:<addr> becomes {Kind: "acc", Value: "<addr>"}
+type TopicKey struct {
+	Kind  string
+	Value string
+}
+
+//safeB64 maps base64 to its URL safe alphabet so notes and groups
+//do not clash with broker topic separators and wildcards
+var safeB64 = strings.NewReplacer("+", "-", "/", "_", "=", "")
+
+//TopicKeys returns the same keys as TxWrap.Topics in a form usable as broker topic levels.
+//Kinds are lower case, note and group values are URL safe base64 and empty values are skipped.
+func TopicKeys(txw *algod.TxWrap) []TopicKey {
+	topics := txw.Topics()
+	keys := make([]TopicKey, 0, len(topics))
+	for _, t := range topics {
+		a := strings.SplitN(t, ":", 2)
+		if len(a) != 2 || a[1] == "" {
+			continue
+		}
+		keys = append(keys, TopicKey{Kind: strings.ToLower(a[0]), Value: safeB64.Replace(a[1])})
+	}
+	return keys
+}
+
+//TxRefs are the main receiver, asset and app of a txn, zero when the txn type has none
+type TxRefs struct {
+	Receiver types.Address
+	Asset    uint64
+	App      uint64
+}
+
+//Refs picks the receiver, asset and app fields that matter for the txn type
+func Refs(txw *algod.TxWrap) TxRefs {
+	tx := &txw.Txn.Txn
+	var r TxRefs
+	switch tx.Type {
+	case types.PaymentTx:
+		r.Receiver = tx.Receiver
+	case types.AssetTransferTx:
+		r.Receiver = tx.AssetReceiver
+		r.Asset = uint64(tx.XferAsset)
+	case types.AssetConfigTx:
+		r.Asset = uint64(tx.ConfigAsset)
+	case types.AssetFreezeTx:
+		r.Receiver = tx.FreezeAccount
+		r.Asset = uint64(tx.FreezeAsset)
+	case types.ApplicationCallTx:
+		r.App = uint64(tx.ApplicationID)
+	}
+	return r
+}
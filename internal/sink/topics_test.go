@@ -0,0 +1,98 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package sink
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+var (
+	alice = types.Address{1}
+	bob   = types.Address{2}
+)
+
+func wrap(tx types.Transaction) *algod.TxWrap {
+	return &algod.TxWrap{TxId: "TXID", Txn: &types.SignedTxnInBlock{SignedTxnWithAD: types.SignedTxnWithAD{SignedTxn: types.SignedTxn{Txn: tx}}}, Round: 10, Intra: 2, Key: "10-2"}
+}
+
+func TestTopicKeys(t *testing.T) {
+	pay := types.Transaction{Type: types.PaymentTx}
+	pay.Sender, pay.Receiver = alice, bob
+	note := pay
+	note.Note = []byte{0xfb, 0xff, 0xfe}
+	axfer := types.Transaction{Type: types.AssetTransferTx}
+	axfer.Sender, axfer.AssetReceiver, axfer.XferAsset = alice, bob, 31566704
+	grouped := pay
+	grouped.Group = types.Digest{0xff}
+
+	tests := []struct {
+		name string
+		tx   types.Transaction
+		want []TopicKey
+	}{
+		{"pay", pay, []TopicKey{{"acc", alice.String()}, {"acc", bob.String()}}},
+		{"note is url safe", note, []TopicKey{{"acc", alice.String()}, {"acc", bob.String()}, {"note", "-__-"}}},
+		{"axfer", axfer, []TopicKey{{"acc", alice.String()}, {"acc", bob.String()}, {"asa", "31566704"}}},
+		{"group", grouped, []TopicKey{{"acc", alice.String()}, {"acc", bob.String()}, {"grp", "_wAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TopicKeys(wrap(tt.tx))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefs(t *testing.T) {
+	pay := types.Transaction{Type: types.PaymentTx}
+	pay.Receiver = bob
+	axfer := types.Transaction{Type: types.AssetTransferTx}
+	axfer.AssetReceiver, axfer.XferAsset = bob, 7
+	acfg := types.Transaction{Type: types.AssetConfigTx}
+	acfg.ConfigAsset = 8
+	afrz := types.Transaction{Type: types.AssetFreezeTx}
+	afrz.FreezeAccount, afrz.FreezeAsset = bob, 9
+	appl := types.Transaction{Type: types.ApplicationCallTx}
+	appl.ApplicationID = 10
+	appl.ForeignAssets = []types.AssetIndex{11}
+	keyreg := types.Transaction{Type: types.KeyRegistrationTx}
+
+	tests := []struct {
+		name string
+		tx   types.Transaction
+		want TxRefs
+	}{
+		{"pay", pay, TxRefs{Receiver: bob}},
+		{"axfer", axfer, TxRefs{Receiver: bob, Asset: 7}},
+		{"acfg", acfg, TxRefs{Asset: 8}},
+		{"afrz", afrz, TxRefs{Receiver: bob, Asset: 9}},
+		{"appl ignores foreign refs", appl, TxRefs{App: 10}},
+		{"keyreg", keyreg, TxRefs{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Refs(wrap(tt.tx)); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package sqlite
+
+//schema is created on start, statements are idempotent
+const schema = `
+CREATE TABLE IF NOT EXISTS blocks (
+	round      INTEGER PRIMARY KEY,
+	ts         INTEGER NOT NULL,
+	genesis_id TEXT NOT NULL,
+	txn_count  INTEGER NOT NULL,
+	header     TEXT NOT NULL,
+	raw        BLOB
+);
+
+-- amount has no type so values above the INTEGER range stay exact as TEXT
+CREATE TABLE IF NOT EXISTS txns (
+	round    INTEGER NOT NULL,
+	intra    INTEGER NOT NULL,
+	txid     TEXT NOT NULL,
+	type     TEXT NOT NULL,
+	sender   TEXT NOT NULL,
+	receiver TEXT,
+	amount,
+	asset    INTEGER,
+	app      INTEGER,
+	fee      INTEGER NOT NULL,
+	grp      TEXT,
+	note     BLOB,
+	ts       INTEGER NOT NULL,
+	txn      TEXT NOT NULL,
+	PRIMARY KEY (round, intra)
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS txns_txid ON txns (txid);
+CREATE INDEX IF NOT EXISTS txns_sender ON txns (sender, round);
+
+-- same keys as the pub/sub channels: acc, asa, app, note (base64 prefix) and grp
+CREATE TABLE IF NOT EXISTS txn_participation (
+	kind  TEXT NOT NULL,
+	key   TEXT NOT NULL,
+	round INTEGER NOT NULL,
+	intra INTEGER NOT NULL,
+	PRIMARY KEY (kind, key, round, intra)
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS txn_participation_round ON txn_participation (round);
+
+CREATE TABLE IF NOT EXISTS checkpoint (
+	id      INTEGER PRIMARY KEY CHECK (id = 1),
+	round   INTEGER NOT NULL,
+	updated INTEGER NOT NULL
+);
+`
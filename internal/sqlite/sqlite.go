@@ -0,0 +1,271 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algonode/algostreamer/internal/utils"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	defaultPath = "algostream.db"
+	//pruneTxnsEvery rounds the txn cap is applied, finding the cut walks MaxTxns rows of the index
+	pruneTxnsEvery = 100
+)
+
+type SqliteConfig struct {
+	Path string `json:"path"`
+	//Raw stores the msgpack block in blocks.raw
+	Raw bool `json:"raw"`
+	//MaxBlocks and MaxTxns keep only the newest blocks and txns, 0 keeps everything
+	MaxBlocks uint64 `json:"maxBlocks"`
+	MaxTxns   uint64 `json:"maxTxns"`
+}
+
+type sqliteSink struct {
+	name string
+	cfg  *SqliteConfig
+	db   *sql.DB
+}
+
+func init() {
+	sink.Register("sqlite", func() sink.Sink { return &sqliteSink{} })
+}
+
+func dsn(path string, params ...string) string {
+	q := url.Values{}
+	q.Add("_pragma", "busy_timeout(10000)")
+	for _, p := range params {
+		q.Add("_pragma", p)
+	}
+	return "file:" + path + "?" + q.Encode()
+}
+
+//OpenReadOnly opens the database for queries while the streamer writes to it,
+//WAL mode lets readers see the last committed block without blocking the writer
+func OpenReadOnly(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dsn(path, "query_only(1)")+"&mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (s *sqliteSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &SqliteConfig{}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, s.cfg); err != nil {
+			return fmt.Errorf("[SQLITE] invalid config: %s", err)
+		}
+	}
+	if s.cfg.Path == "" {
+		s.cfg.Path = defaultPath
+	}
+	db, err := sql.Open("sqlite", dsn(s.cfg.Path, "journal_mode(WAL)", "synchronous(NORMAL)"))
+	if err != nil {
+		return fmt.Errorf("[SQLITE] %s", err)
+	}
+	//single writer connection
+	db.SetMaxOpenConns(1)
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		db.Close()
+		return fmt.Errorf("[SQLITE] creating schema: %s", err)
+	}
+	s.db = db
+	return nil
+}
+
+//HandleBlock writes the block, its txns and participation rows together with the checkpoint
+//in a single transaction, rounds at or below the checkpoint are skipped
+func (s *sqliteSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	start := time.Now()
+	round := uint64(b.Block.Round)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("[SQLITE] %s", err)
+	}
+	defer tx.Rollback()
+
+	var cp int64 = -1
+	if err := tx.QueryRowContext(ctx, `SELECT round FROM checkpoint WHERE id = 1`).Scan(&cp); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("[SQLITE] %s", err)
+	}
+	if int64(round) <= cp {
+		return nil
+	}
+
+	if len(b.Msgs) > 0 {
+		header, err := utils.EncodeJson(b.Block.BlockHeader)
+		if err != nil {
+			return fmt.Errorf("[SQLITE] %s", err)
+		}
+		var raw []byte
+		if s.cfg.Raw {
+			raw = b.BlockRaw
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO blocks (round, ts, genesis_id, txn_count, header, raw) VALUES (?, ?, ?, ?, ?, ?)`,
+			int64(round), b.Block.TimeStamp, b.Block.GenesisID, len(b.Block.Payset), string(header), raw); err != nil {
+			return fmt.Errorf("[SQLITE] block %d: %s", round, err)
+		}
+	}
+
+	txStmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO txns (round, intra, txid, type, sender, receiver, amount, asset, app, fee, grp, note, ts, txn)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("[SQLITE] %s", err)
+	}
+	defer txStmt.Close()
+	partStmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO txn_participation (kind, key, round, intra) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("[SQLITE] %s", err)
+	}
+	defer partStmt.Close()
+
+	txns := 0
+	for _, t := range b.Txns {
+		body, err := t.Encode(t.TxWrap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][SQLITE] %s\n", err)
+			continue
+		}
+		r := sink.Row(b.BlockWrap, t.TxWrap)
+		if _, err := txStmt.ExecContext(ctx, int64(r.Round), r.Intra, r.TxId, r.Type, r.Sender, nullStr(r.Receiver), amount(r.Amount),
+			nullInt(r.Asset), nullInt(r.App), int64(r.Fee), nullStr(r.Group), r.Note, r.Ts, string(body)); err != nil {
+			return fmt.Errorf("[SQLITE] txn %s: %s", t.Key, err)
+		}
+		for _, topic := range t.Topics() {
+			a := strings.SplitN(topic, ":", 2)
+			if len(a) != 2 || a[1] == "" {
+				continue
+			}
+			if _, err := partStmt.ExecContext(ctx, strings.ToLower(a[0]), a[1], int64(r.Round), r.Intra); err != nil {
+				return fmt.Errorf("[SQLITE] txn %s: %s", t.Key, err)
+			}
+		}
+		txns++
+	}
+
+	if err := s.prune(ctx, tx, round); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO checkpoint (id, round, updated) VALUES (1, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET round = excluded.round, updated = excluded.updated`, int64(round), time.Now().Unix()); err != nil {
+		return fmt.Errorf("[SQLITE] checkpoint: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("[SQLITE] block %d: %s", round, err)
+	}
+	fmt.Fprintf(os.Stderr, "[INFO][SQLITE][%s] Block %d committed in %s (%d txn)\n", s.name, round, time.Since(start), txns)
+	return nil
+}
+
+//prune applies retention like the Redis streams MAX_Blocks and MAX_TXN caps,
+//the txn cap is approximate like theirs and is applied every pruneTxnsEvery rounds
+func (s *sqliteSink) prune(ctx context.Context, tx *sql.Tx, round uint64) error {
+	if s.cfg.MaxBlocks > 0 && round >= s.cfg.MaxBlocks {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM blocks WHERE round <= ?`, int64(round-s.cfg.MaxBlocks)); err != nil {
+			return fmt.Errorf("[SQLITE] prune blocks: %s", err)
+		}
+	}
+	if s.cfg.MaxTxns > 0 && round%pruneTxnsEvery == 0 {
+		var (
+			r int64
+			i int
+		)
+		err := tx.QueryRowContext(ctx, `SELECT round, intra FROM txns ORDER BY round DESC, intra DESC LIMIT 1 OFFSET ?`, int64(s.cfg.MaxTxns)).Scan(&r, &i)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("[SQLITE] prune txns: %s", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM txns WHERE round < ? OR (round = ? AND intra <= ?)`, r, r, i); err != nil {
+			return fmt.Errorf("[SQLITE] prune txns: %s", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM txn_participation WHERE round < ? OR (round = ? AND intra <= ?)`, r, r, i); err != nil {
+			return fmt.Errorf("[SQLITE] prune txns: %s", err)
+		}
+	}
+	return nil
+}
+
+func nullStr(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullInt(v uint64) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return int64(v)
+}
+
+//amount keeps uint64 values exact, SQLite integers are signed
+func amount(v uint64) interface{} {
+	if v > math.MaxInt64 {
+		return strconv.FormatUint(v, 10)
+	}
+	return int64(v)
+}
+
+func (s *sqliteSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	return nil
+}
+
+func (s *sqliteSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *sqliteSink) Close(ctx context.Context) error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+//LastCommittedRound reads the checkpoint row
+func (s *sqliteSink) LastCommittedRound(ctx context.Context) (uint64, error) {
+	var cp int64
+	err := s.db.QueryRowContext(ctx, `SELECT round FROM checkpoint WHERE id = 1`).Scan(&cp)
+	if err == sql.ErrNoRows {
+		return 0, sink.ErrNoCheckpoint
+	}
+	if err != nil {
+		return 0, fmt.Errorf("[SQLITE] error getting checkpoint %v", err)
+	}
+	return uint64(cp), nil
+}
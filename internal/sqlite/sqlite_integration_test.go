@@ -0,0 +1,123 @@
+//go:build integration
+// +build integration
+
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+func testSink(t *testing.T, cfg SqliteConfig) *sqliteSink {
+	ctx := context.Background()
+	cfg.Path = filepath.Join(t.TempDir(), "test.db")
+	j, _ := json.Marshal(&cfg)
+	s := &sqliteSink{}
+	if err := s.Init(ctx, "test", j); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close(ctx) })
+	return s
+}
+
+//testBlock has two payments between the same accounts
+func testBlock(round uint64) *sink.Block {
+	bw := &algod.BlockWrap{Block: &types.Block{BlockHeader: types.BlockHeader{Round: types.Round(round), TimeStamp: 1660000000}}}
+	b := &sink.Block{BlockWrap: bw, Msgs: []sink.Msg{{}}}
+	for i := 0; i < 2; i++ {
+		tx := types.Transaction{Type: types.PaymentTx}
+		tx.Sender, tx.Receiver, tx.Amount = types.Address{1}, types.Address{2}, types.MicroAlgos(round)
+		b.Txns = append(b.Txns, &sink.Tx{TxWrap: &algod.TxWrap{
+			TxId:  fmt.Sprintf("T%d-%d", round, i),
+			Txn:   &types.SignedTxnInBlock{SignedTxnWithAD: types.SignedTxnWithAD{SignedTxn: types.SignedTxn{Txn: tx}}},
+			Round: round,
+			Intra: i,
+			Key:   fmt.Sprintf("%d-%d", round, i),
+		}})
+	}
+	return b
+}
+
+func count(t *testing.T, s *sqliteSink, table string) int {
+	var n int
+	if err := s.db.QueryRow(`SELECT count(*) FROM ` + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestHandleBlock(t *testing.T) {
+	s := testSink(t, SqliteConfig{})
+	ctx := context.Background()
+	if _, err := s.LastCommittedRound(ctx); !errors.Is(err, sink.ErrNoCheckpoint) {
+		t.Fatalf("empty database: %v", err)
+	}
+	for _, round := range []uint64{1, 2, 3, 3, 2} {
+		if err := s.HandleBlock(ctx, testBlock(round)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	last, err := s.LastCommittedRound(ctx)
+	if err != nil || last != 3 {
+		t.Errorf("LastCommittedRound = %d, %v", last, err)
+	}
+	//each txn has a row per account
+	for table, want := range map[string]int{"blocks": 3, "txns": 6, "txn_participation": 12} {
+		if n := count(t, s, table); n != want {
+			t.Errorf("%s has %d rows, want %d", table, n, want)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    SqliteConfig
+		rounds []uint64
+		blocks int
+		txns   int
+	}{
+		{"blocks", SqliteConfig{MaxBlocks: 2}, []uint64{1, 2, 3, 4}, 2, 8},
+		{"txns wait for the prune round", SqliteConfig{MaxTxns: 3}, []uint64{97, 98, 99}, 3, 6},
+		{"txns", SqliteConfig{MaxTxns: 3}, []uint64{97, 98, 99, 100}, 4, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testSink(t, tt.cfg)
+			for _, round := range tt.rounds {
+				if err := s.HandleBlock(context.Background(), testBlock(round)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if n := count(t, s, "blocks"); n != tt.blocks {
+				t.Errorf("%d blocks, want %d", n, tt.blocks)
+			}
+			if n := count(t, s, "txns"); n != tt.txns {
+				t.Errorf("%d txns, want %d", n, tt.txns)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package sse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/sink"
+)
+
+const (
+	defaultListen = ":8091"
+	defaultRing   = 100
+	defaultBuffer = 64
+	heartbeat     = 15 * time.Second
+	retryMs       = 3000
+)
+
+type SseConfig struct {
+	//Listen is the address of the embedded HTTP server
+	Listen string `json:"listen"`
+	//Ring is the number of recent blocks kept for reconnecting clients
+	Ring int `json:"ring"`
+	//Buffer is the number of events queued per client,
+	//clients falling further behind are disconnected
+	Buffer int `json:"buffer"`
+	//Origins sets Access-Control-Allow-Origin, "*" allows any
+	Origins []string `json:"origins"`
+}
+
+//blockSummary is the data of block events
+type blockSummary struct {
+	Round    uint64 `json:"round"`
+	Ts       int64  `json:"ts"`
+	Txns     int    `json:"txns"`
+	Proposer string `json:"proposer,omitempty"`
+}
+
+type sseSink struct {
+	sink.NoCheckpoint
+	name   string
+	cfg    *SseConfig
+	blocks *stream
+	status *stream
+	srv    *http.Server
+	quit   chan struct{}
+}
+
+func init() {
+	sink.Register("sse", func() sink.Sink { return &sseSink{} })
+}
+
+func (s *sseSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &SseConfig{}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, s.cfg); err != nil {
+			return fmt.Errorf("[SSE] invalid config: %s", err)
+		}
+	}
+	if s.cfg.Listen == "" {
+		s.cfg.Listen = defaultListen
+	}
+	if s.cfg.Ring <= 0 {
+		s.cfg.Ring = defaultRing
+	}
+	if s.cfg.Buffer <= 0 {
+		s.cfg.Buffer = defaultBuffer
+	}
+	s.blocks = newStream(s.cfg.Ring)
+	s.status = newStream(0)
+	s.quit = make(chan struct{})
+
+	ln, err := net.Listen("tcp", s.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("[SSE] %s", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events/blocks", func(w http.ResponseWriter, r *http.Request) { s.serve(w, r, s.blocks, true) })
+	mux.HandleFunc("/events/status", func(w http.ResponseWriter, r *http.Request) { s.serve(w, r, s.status, false) })
+	s.srv = &http.Server{Handler: mux}
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "[!ERR][SSE][%s] %s\n", s.name, err)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "[INFO][SSE][%s] Listening on %s\n", s.name, s.cfg.Listen)
+	return nil
+}
+
+func (s *sseSink) allowOrigin(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	for _, o := range s.cfg.Origins {
+		if o == "*" || o == origin {
+			w.Header().Set("Access-Control-Allow-Origin", o)
+			w.Header().Set("Vary", "Origin")
+			return
+		}
+	}
+}
+
+//serve streams events until the client goes away, falls behind or the sink closes.
+//Block clients sending Last-Event-ID (or ?lastEventId=) get the kept blocks after that round first.
+func (s *sseSink) serve(w http.ResponseWriter, r *http.Request, st *stream, replay bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	fl, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	var after uint64
+	if replay {
+		last := r.Header.Get("Last-Event-ID")
+		if last == "" {
+			last = r.URL.Query().Get("lastEventId")
+		}
+		if last == "" {
+			replay = false
+		} else if v, err := strconv.ParseUint(last, 10, 64); err == nil {
+			after = v
+		} else {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	sub, backlog, missed := st.subscribe(s.cfg.Buffer, after, replay)
+	defer st.unsubscribe(sub)
+
+	s.allowOrigin(w, r)
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", retryMs)
+	if missed[0] > 0 {
+		j, _ := json.Marshal(map[string]uint64{"from": missed[0], "to": missed[1]})
+		w.Write(format("gap", 0, j))
+	}
+	for _, e := range backlog {
+		if _, err := w.Write(e); err != nil {
+			return
+		}
+	}
+	fl.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case e := <-sub.ch:
+			if _, err := w.Write(e); err != nil {
+				return
+			}
+			fl.Flush()
+		case <-ticker.C:
+			if _, err := w.Write([]byte(": ping\n\n")); err != nil {
+				return
+			}
+			fl.Flush()
+		case <-sub.slow:
+			fmt.Fprintf(os.Stderr, "[WARN][SSE][%s] Disconnecting slow consumer %s\n", s.name, r.RemoteAddr)
+			return
+		case <-r.Context().Done():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+//HandleBlock publishes a header summary of blocks that are not routed elsewhere by rules
+func (s *sseSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	for i := range b.Msgs {
+		if b.Msgs[i].Topic != "" {
+			continue
+		}
+		round := uint64(b.Block.Round)
+		sum := blockSummary{
+			Round: round,
+			Ts:    b.Block.TimeStamp,
+			Txns:  len(b.Block.Payset),
+		}
+		if !b.Proposer.IsZero() {
+			sum.Proposer = b.Proposer.String()
+		}
+		j, err := json.Marshal(sum)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][SSE] %s\n", err)
+			return nil
+		}
+		s.blocks.publish(round, "", format("block", round, j))
+		break
+	}
+	return nil
+}
+
+//HandleStatus forwards node status updates, new clients get the last status of every node
+func (s *sseSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	for i := range status.Msgs {
+		if status.Msgs[i].Topic != "" {
+			continue
+		}
+		j, err := status.Msgs[i].Encode(status.Status)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][SSE] %s\n", err)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, j); err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][SSE] %s\n", err)
+			continue
+		}
+		s.status.publish(status.LastRound, status.NodeId, format("status", 0, buf.Bytes()))
+	}
+	return nil
+}
+
+func (s *sseSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+//Close ends open streams first, Shutdown waits for handlers to return
+func (s *sseSink) Close(ctx context.Context) error {
+	if s.srv == nil {
+		return nil
+	}
+	close(s.quit)
+	return s.srv.Shutdown(ctx)
+}
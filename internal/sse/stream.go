@@ -0,0 +1,125 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package sse
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+//event is a formatted server-sent event
+type event struct {
+	round uint64
+	data  []byte
+}
+
+//subscriber receives events of one stream
+type subscriber struct {
+	ch chan []byte
+	//slow is closed when the subscriber falls behind and gets dropped
+	slow chan struct{}
+}
+
+//stream fans events out to subscribers.
+//It keeps the last events by round for catch up and the last event per key, e.g. per node.
+type stream struct {
+	mu   sync.Mutex
+	ring []event
+	size int
+	last map[string][]byte
+	subs map[*subscriber]struct{}
+}
+
+func newStream(size int) *stream {
+	return &stream{size: size, last: make(map[string][]byte), subs: make(map[*subscriber]struct{})}
+}
+
+//format builds the event, a zero id leaves it out
+func format(name string, id uint64, data []byte) []byte {
+	var buf []byte
+	buf = append(buf, "event: "...)
+	buf = append(buf, name...)
+	buf = append(buf, '\n')
+	if id > 0 {
+		buf = append(buf, "id: "...)
+		buf = strconv.AppendUint(buf, id, 10)
+		buf = append(buf, '\n')
+	}
+	buf = append(buf, "data: "...)
+	buf = append(buf, data...)
+	buf = append(buf, "\n\n"...)
+	return buf
+}
+
+//publish keeps the event and queues it for every subscriber, subscribers with a full queue are dropped
+func (s *stream) publish(round uint64, key string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size > 0 {
+		if len(s.ring) == s.size {
+			copy(s.ring, s.ring[1:])
+			s.ring = s.ring[:len(s.ring)-1]
+		}
+		s.ring = append(s.ring, event{round: round, data: data})
+	}
+	if key != "" {
+		s.last[key] = data
+	}
+	for sub := range s.subs {
+		select {
+		case sub.ch <- data:
+		default:
+			close(sub.slow)
+			delete(s.subs, sub)
+		}
+	}
+}
+
+//subscribe registers a subscriber under the stream lock, so the backlog and live events neither overlap nor leave a gap.
+//With replay the backlog holds the kept events after round "after" and missed is the range of rounds no longer kept.
+//The last event of every key is always part of the backlog.
+func (s *stream) subscribe(buffer int, after uint64, replay bool) (sub *subscriber, backlog [][]byte, missed [2]uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub = &subscriber{ch: make(chan []byte, buffer), slow: make(chan struct{})}
+	s.subs[sub] = struct{}{}
+	if replay && len(s.ring) > 0 {
+		if first := s.ring[0].round; first > after+1 {
+			missed = [2]uint64{after + 1, first - 1}
+		}
+		for _, e := range s.ring {
+			if e.round > after {
+				backlog = append(backlog, e.data)
+			}
+		}
+	}
+	keys := make([]string, 0, len(s.last))
+	for k := range s.last {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		backlog = append(backlog, s.last[k])
+	}
+	return sub, backlog, missed
+}
+
+func (s *stream) unsubscribe(sub *subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subs, sub)
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package sse
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		id   uint64
+		data string
+		want string
+	}{
+		{"block", 10, `{"round":10}`, "event: block\nid: 10\ndata: {\"round\":10}\n\n"},
+		{"status", 0, `{}`, "event: status\ndata: {}\n\n"},
+	}
+	for _, tt := range tests {
+		if got := string(format(tt.name, tt.id, []byte(tt.data))); got != tt.want {
+			t.Errorf("format(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		rounds  []uint64
+		keys    []string
+		after   uint64
+		replay  bool
+		backlog []string
+		missed  [2]uint64
+	}{
+		{name: "no replay", size: 3, rounds: []uint64{1, 2, 3}},
+		{name: "after the last kept", size: 3, rounds: []uint64{1, 2, 3}, after: 3, replay: true},
+		{name: "kept rounds", size: 3, rounds: []uint64{1, 2, 3}, after: 1, replay: true, backlog: []string{"2", "3"}},
+		{name: "older than kept", size: 2, rounds: []uint64{1, 2, 3, 4}, after: 0, replay: true, backlog: []string{"3", "4"}, missed: [2]uint64{1, 2}},
+		{name: "nothing kept", size: 0, rounds: []uint64{1, 2}, after: 0, replay: true},
+		{name: "last per key", size: 3, rounds: []uint64{1, 2}, keys: []string{"b", "a"}, backlog: []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStream(tt.size)
+			for _, r := range tt.rounds {
+				s.publish(r, "", []byte(strconv.FormatUint(r, 10)))
+			}
+			for _, k := range tt.keys {
+				s.publish(0, k, []byte(k))
+			}
+			sub, backlog, missed := s.subscribe(10, tt.after, tt.replay)
+			var got []string
+			for _, b := range backlog {
+				got = append(got, string(b))
+			}
+			if !reflect.DeepEqual(got, tt.backlog) {
+				t.Errorf("backlog %v, want %v", got, tt.backlog)
+			}
+			if missed != tt.missed {
+				t.Errorf("missed %v, want %v", missed, tt.missed)
+			}
+
+			//live events continue right after the backlog
+			s.publish(100, "", []byte("live"))
+			if e := <-sub.ch; string(e) != "live" {
+				t.Errorf("live event %s", e)
+			}
+		})
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	s := newStream(10)
+	sub, _, _ := s.subscribe(1, 0, false)
+	s.publish(1, "", []byte("1"))
+	s.publish(2, "", []byte("2"))
+	select {
+	case <-sub.slow:
+	default:
+		t.Error("subscriber with a full queue was not dropped")
+	}
+	if len(s.subs) != 0 {
+		t.Error("dropped subscriber is still registered")
+	}
+}
@@ -29,9 +29,29 @@ import (
 
 type eternalFn func(ctx context.Context) error
 
-func Backoff(ctx context.Context, fn eternalFn, timeout time.Duration, wait time.Duration, maxwait time.Duration) error {
+//BackoffOpt changes how Backoff retries
+type BackoffOpt func(*backoffOpts)
+
+type backoffOpts struct {
+	tries int
+}
+
+//MaxTries makes Backoff give up after n attempts and return the last error instead of printing it
+func MaxTries(n int) BackoffOpt {
+	return func(o *backoffOpts) {
+		o.tries = n
+	}
+}
+
+//Backoff calls fn until it succeeds, ctx gets cancelled or fn returns a Permanent error.
+//The wait between attempts doubles up to maxwait, 0 keeps it constant.
+func Backoff(ctx context.Context, fn eternalFn, timeout time.Duration, wait time.Duration, maxwait time.Duration, opts ...BackoffOpt) error {
+	var o backoffOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
 	//Loop until Algoverse gets cancelled
-	for {
+	for i := 1; ; i++ {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
@@ -42,7 +62,16 @@ func Backoff(ctx context.Context, fn eternalFn, timeout time.Duration, wait time
 			return nil
 		}
 		cancel()
-		fmt.Fprintf(os.Stderr, err.Error())
+		if _, ok := err.(Permanent); ok {
+			return err
+		}
+		if o.tries > 0 {
+			if i >= o.tries {
+				return err
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, err.Error())
+		}
 
 		//keep an eye on cancellation while backing off
 		if wait > 0 {
@@ -60,6 +89,19 @@ func Backoff(ctx context.Context, fn eternalFn, timeout time.Duration, wait time
 	}
 }
 
+//Permanent wraps errors that retrying cannot fix
+type Permanent struct {
+	Err error
+}
+
+func (p Permanent) Error() string {
+	return p.Err.Error()
+}
+
+func (p Permanent) Unwrap() error {
+	return p.Err
+}
+
 func LoadJSONCFromFile(filename string, object interface{}) (err error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
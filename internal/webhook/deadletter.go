@@ -0,0 +1,133 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/rdb"
+	"github.com/go-redis/redis/v8"
+)
+
+const deadLetterMaxLen = 100_000
+
+//DeadLetterConfig stores undeliverable events in a file or a Redis stream
+type DeadLetterConfig struct {
+	File   string           `json:"file"`
+	Redis  *rdb.RedisConfig `json:"redis"`
+	Stream string           `json:"stream"`
+	//MaxLen caps the Redis stream length
+	MaxLen int64 `json:"maxlen"`
+}
+
+type deadEntry struct {
+	Ts       time.Time       `json:"ts"`
+	URL      string          `json:"url"`
+	Event    string          `json:"event"`
+	Key      string          `json:"key"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Body     json.RawMessage `json:"body"`
+}
+
+type deadLetter struct {
+	mu     sync.Mutex
+	f      *os.File
+	rc     *redis.Client
+	stream string
+	maxLen int64
+}
+
+func openDeadLetter(cfg *DeadLetterConfig) (*deadLetter, error) {
+	dl := &deadLetter{}
+	switch {
+	case cfg.File != "":
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("[HOOK] dead letter: %s", err)
+		}
+		dl.f = f
+	case cfg.Redis != nil:
+		if cfg.Stream == "" {
+			return nil, fmt.Errorf("[HOOK] dead letter: redis stream is missing")
+		}
+		dl.rc = redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			Username: cfg.Redis.Username,
+			DB:       cfg.Redis.DB,
+		})
+		dl.stream = cfg.Stream
+		dl.maxLen = cfg.MaxLen
+		if dl.maxLen <= 0 {
+			dl.maxLen = deadLetterMaxLen
+		}
+	default:
+		return nil, fmt.Errorf("[HOOK] dead letter: configure file or redis")
+	}
+	return dl, nil
+}
+
+//write stores the event, without a dead letter undeliverable events are only logged
+func (dl *deadLetter) write(ctx context.Context, e *event, cause error, attempts int) error {
+	if dl == nil {
+		return nil
+	}
+	j, err := json.Marshal(&deadEntry{
+		Ts:       time.Now().UTC(),
+		URL:      e.url,
+		Event:    e.kind,
+		Key:      e.key,
+		Attempts: attempts,
+		Error:    cause.Error(),
+		Body:     json.RawMessage(e.body),
+	})
+	if err != nil {
+		return fmt.Errorf("[HOOK] dead letter: %s", err)
+	}
+	if dl.rc != nil {
+		if err := dl.rc.XAdd(ctx, &redis.XAddArgs{
+			Stream: dl.stream,
+			MaxLen: dl.maxLen,
+			Approx: true,
+			Values: map[string]interface{}{"json": string(j)},
+		}).Err(); err != nil {
+			return fmt.Errorf("[HOOK] dead letter: %s", err)
+		}
+		return nil
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if _, err := dl.f.Write(append(j, '\n')); err != nil {
+		return fmt.Errorf("[HOOK] dead letter: %s", err)
+	}
+	return nil
+}
+
+func (dl *deadLetter) close() error {
+	if dl == nil {
+		return nil
+	}
+	if dl.rc != nil {
+		return dl.rc.Close()
+	}
+	return dl.f.Close()
+}
@@ -0,0 +1,348 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algonode/algostreamer/internal/utils"
+)
+
+const (
+	defaultTimeout    = 10
+	defaultRetries    = 5
+	defaultBackoff    = 1
+	defaultMaxBackoff = 60
+	defaultBreaker    = 10
+)
+
+type WebhookConfig struct {
+	URL string `json:"url"`
+	//Secret signs requests with HMAC-SHA256, see README for the signature format
+	Secret  string            `json:"secret"`
+	Headers map[string]string `json:"headers"`
+	//Timeout is the number of seconds for a single request
+	Timeout int `json:"timeout"`
+	//Retries is the number of attempts before an event goes to the dead letter
+	Retries int `json:"retries"`
+	//Backoff and MaxBackoff are the first and the longest wait between attempts in seconds
+	Backoff    int `json:"backoff"`
+	MaxBackoff int `json:"maxBackoff"`
+	//Workers deliver events of a block concurrently, 1 keeps the intra order
+	Workers int `json:"workers"`
+	//Breaker is the number of consecutive undeliverable events
+	//after which the rest of the block goes straight to the dead letter
+	Breaker int `json:"breaker"`
+	//Blocks and Status post block and node status events as well as txns
+	Blocks     bool              `json:"blocks"`
+	Status     bool              `json:"status"`
+	DeadLetter *DeadLetterConfig `json:"deadLetter"`
+	TLS        *utils.TLSConfig  `json:"tls"`
+}
+
+//event is a single delivery
+type event struct {
+	url  string
+	kind string
+	key  string
+	body []byte
+}
+
+func (e *event) id() string {
+	return e.kind + " " + e.key + " " + e.url
+}
+
+type webhookSink struct {
+	sink.NoCheckpoint
+	name string
+	cfg  *WebhookConfig
+	hc   *http.Client
+	dl   *deadLetter
+	//done holds events of round that were delivered or dead lettered
+	//so a retried block does not post them again
+	round uint64
+	done  map[string]bool
+}
+
+func init() {
+	sink.Register("webhook", func() sink.Sink { return &webhookSink{} })
+}
+
+func (s *webhookSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &WebhookConfig{}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, s.cfg); err != nil {
+			return fmt.Errorf("[HOOK] invalid config: %s", err)
+		}
+	}
+	if s.cfg.URL == "" {
+		return fmt.Errorf("[HOOK] url is missing")
+	}
+	if s.cfg.Timeout <= 0 {
+		s.cfg.Timeout = defaultTimeout
+	}
+	if s.cfg.Retries <= 0 {
+		s.cfg.Retries = defaultRetries
+	}
+	if s.cfg.Backoff <= 0 {
+		s.cfg.Backoff = defaultBackoff
+	}
+	if s.cfg.MaxBackoff <= 0 {
+		s.cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if s.cfg.Workers <= 0 {
+		s.cfg.Workers = 1
+	}
+	if s.cfg.Breaker <= 0 {
+		s.cfg.Breaker = defaultBreaker
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if s.cfg.TLS != nil {
+		tc, err := s.cfg.TLS.Load()
+		if err != nil {
+			return fmt.Errorf("[HOOK] %s", err)
+		}
+		tr.TLSClientConfig = tc
+	}
+	s.hc = &http.Client{Transport: tr}
+	if s.cfg.DeadLetter != nil {
+		dl, err := openDeadLetter(s.cfg.DeadLetter)
+		if err != nil {
+			return err
+		}
+		s.dl = dl
+	}
+	return nil
+}
+
+//HandleBlock delivers txns (and the block if enabled) of a round.
+//Events that cannot be delivered go to the dead letter and do not hold back the stream,
+//only a failing dead letter makes the block retry and then only the events it lost are posted again.
+func (s *webhookSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	start := time.Now()
+	round := uint64(b.Block.Round)
+	if s.done == nil || s.round != round {
+		s.round = round
+		s.done = make(map[string]bool)
+	}
+	events := make([]*event, 0, len(b.Txns)+len(b.Msgs))
+	for _, tx := range b.Txns {
+		body, err := tx.Encode(tx.TxWrap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][HOOK] %s\n", err)
+			continue
+		}
+		events = append(events, &event{url: tx.Topic, kind: "tx", key: tx.Key, body: body})
+	}
+	for i := range b.Msgs {
+		if !s.cfg.Blocks && b.Msgs[i].Topic == "" {
+			continue
+		}
+		body, err := b.Msgs[i].Encode(b.Payload())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][HOOK] %s\n", err)
+			continue
+		}
+		events = append(events, &event{url: b.Msgs[i].Topic, kind: "block", key: strconv.FormatUint(round, 10), body: body})
+	}
+
+	failed, err := s.deliverAll(ctx, events, s.done)
+	if err != nil {
+		return err
+	}
+	s.done = nil
+	if len(events) > 0 {
+		fmt.Fprintf(os.Stderr, "[INFO][HOOK][%s] Block %d: %d delivered, %d dead in %s\n", s.name, round, len(events)-failed, failed, time.Since(start))
+	}
+	return nil
+}
+
+func (s *webhookSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	if !s.cfg.Status {
+		return nil
+	}
+	events := make([]*event, 0, len(status.Msgs))
+	for i := range status.Msgs {
+		body, err := status.Msgs[i].Encode(status.Status)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][HOOK] %s\n", err)
+			continue
+		}
+		key := fmt.Sprintf("%s-%d", status.NodeId, status.LastRound)
+		events = append(events, &event{url: status.Msgs[i].Topic, kind: "status", key: key, body: body})
+	}
+	_, err := s.deliverAll(ctx, events, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!ERR][HOOK] %s\n", err)
+	}
+	return err
+}
+
+//deliverAll posts events using the configured number of workers, skipping and recording in done
+//the ones already handled. Once Breaker events in a row fail the rest are dead lettered without posting.
+//Returns the number of dead lettered events.
+func (s *webhookSink) deliverAll(ctx context.Context, events []*event, done map[string]bool) (int, error) {
+	workers := s.cfg.Workers
+	if workers > len(events) {
+		workers = len(events)
+	}
+	var (
+		next   int64 = -1
+		failed int64
+		streak int64
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		dlErr  error
+	)
+	skip := func(e *event) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return done != nil && done[e.id()]
+	}
+	mark := func(e *event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if done != nil {
+			done[e.id()] = true
+		}
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(events) {
+					return
+				}
+				e := events[i]
+				if e.url == "" {
+					e.url = s.cfg.URL
+				}
+				if skip(e) {
+					continue
+				}
+				var (
+					attempts int
+					err      error
+				)
+				if atomic.LoadInt64(&streak) >= int64(s.cfg.Breaker) {
+					err = fmt.Errorf("circuit open after %d failures", s.cfg.Breaker)
+				} else {
+					attempts, err = s.deliver(ctx, e)
+				}
+				if err == nil {
+					atomic.StoreInt64(&streak, 0)
+					mark(e)
+					continue
+				}
+				if ctx.Err() != nil {
+					mu.Lock()
+					dlErr = ctx.Err()
+					mu.Unlock()
+					return
+				}
+				atomic.AddInt64(&failed, 1)
+				fmt.Fprintf(os.Stderr, "[WARN][HOOK][%s] %s %s undeliverable: %s\n", s.name, e.kind, e.key, err)
+				if atomic.AddInt64(&streak, 1) == int64(s.cfg.Breaker) {
+					fmt.Fprintf(os.Stderr, "[WARN][HOOK][%s] %d events in a row failed, dead lettering the rest\n", s.name, s.cfg.Breaker)
+				}
+				if derr := s.dl.write(ctx, e, err, attempts); derr != nil {
+					mu.Lock()
+					dlErr = derr
+					mu.Unlock()
+					continue
+				}
+				mark(e)
+			}
+		}()
+	}
+	wg.Wait()
+	return int(failed), dlErr
+}
+
+//deliver retries with exponential backoff, client errors other than 408 and 429 are not retried.
+//Returns the number of attempts made.
+func (s *webhookSink) deliver(ctx context.Context, e *event) (int, error) {
+	attempts := 0
+	err := utils.Backoff(ctx, func(actx context.Context) error {
+		attempts++
+		req, err := http.NewRequestWithContext(actx, http.MethodPost, e.url, bytes.NewReader(e.body))
+		if err != nil {
+			return utils.Permanent{Err: err}
+		}
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "algostreamer")
+		req.Header.Set("Idempotency-Key", e.key)
+		req.Header.Set("X-Algo-Event", e.kind)
+		req.Header.Set("X-Algo-Timestamp", ts)
+		if s.cfg.Secret != "" {
+			req.Header.Set("X-Algo-Signature", "sha256="+sign(s.cfg.Secret, ts, e.body))
+		}
+		for k, v := range s.cfg.Headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := s.hc.Do(req)
+		if err != nil {
+			return err
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			return nil
+		case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			return fmt.Errorf("http status %d", resp.StatusCode)
+		default:
+			return utils.Permanent{Err: fmt.Errorf("http status %d", resp.StatusCode)}
+		}
+	}, time.Duration(s.cfg.Timeout)*time.Second, time.Duration(s.cfg.Backoff)*time.Second, time.Duration(s.cfg.MaxBackoff)*time.Second, utils.MaxTries(s.cfg.Retries))
+	return attempts, err
+}
+
+//sign is hex HMAC-SHA256 of "<timestamp>.<body>"
+func sign(secret string, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *webhookSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *webhookSink) Close(ctx context.Context) error {
+	s.hc.CloseIdleConnections()
+	return s.dl.close()
+}
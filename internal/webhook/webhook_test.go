@@ -0,0 +1,164 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret string
+		ts     string
+		body   string
+		want   string
+	}{
+		{"secret", "1660000000", `{"txid":"A"}`, "835d77ef0ab9b14afcac24595ec86850b6fb3a48adbac37f1ad19d5e6b9b2376"},
+		{"", "0", "", "b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3"},
+	}
+	for _, tt := range tests {
+		if got := sign(tt.secret, tt.ts, []byte(tt.body)); got != tt.want {
+			t.Errorf("sign(%q, %q, %q) = %s, want %s", tt.secret, tt.ts, tt.body, got, tt.want)
+		}
+	}
+}
+
+//endpoint accepts the "ok" key, rejects "bad" for good and fails everything else with 503
+type endpoint struct {
+	mu    sync.Mutex
+	posts map[string]int
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Idempotency-Key")
+	e.mu.Lock()
+	e.posts[key]++
+	e.mu.Unlock()
+	switch key {
+	case "ok":
+	case "bad":
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+func newTestSink(t *testing.T, breaker int) (*webhookSink, *endpoint) {
+	ep := &endpoint{posts: make(map[string]int)}
+	srv := httptest.NewServer(ep)
+	t.Cleanup(srv.Close)
+	dl, err := openDeadLetter(&DeadLetterConfig{File: filepath.Join(t.TempDir(), "dead.jsonl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dl.close() })
+	cfg := &WebhookConfig{URL: srv.URL, Timeout: 5, Retries: 2, Workers: 1, Breaker: breaker}
+	return &webhookSink{name: "test", cfg: cfg, hc: srv.Client(), dl: dl}, ep
+}
+
+func events(keys ...string) []*event {
+	evs := make([]*event, len(keys))
+	for i, k := range keys {
+		evs[i] = &event{kind: "tx", key: k, body: []byte("{}")}
+	}
+	return evs
+}
+
+func lines(t *testing.T, path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	n := 0
+	for sc := bufio.NewScanner(f); sc.Scan(); n++ {
+	}
+	return n
+}
+
+func TestDeliverAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []string
+		breaker int
+		posts   map[string]int
+		failed  int
+	}{
+		{"delivered", []string{"ok"}, 10, map[string]int{"ok": 1}, 0},
+		{"client errors are not retried", []string{"bad"}, 10, map[string]int{"bad": 1}, 1},
+		{"retried then dead", []string{"a"}, 10, map[string]int{"a": 2}, 1},
+		{"breaker opens", []string{"a", "b", "c", "d"}, 2, map[string]int{"a": 2, "b": 2}, 4},
+		{"success closes breaker", []string{"a", "ok", "b", "c", "d"}, 2, map[string]int{"a": 2, "ok": 1, "b": 2, "c": 2}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ep := newTestSink(t, tt.breaker)
+			failed, err := s.deliverAll(context.Background(), events(tt.keys...), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if failed != tt.failed {
+				t.Errorf("failed %d, want %d", failed, tt.failed)
+			}
+			if len(ep.posts) != len(tt.posts) {
+				t.Errorf("posts %v, want %v", ep.posts, tt.posts)
+			}
+			for k, n := range tt.posts {
+				if ep.posts[k] != n {
+					t.Errorf("posts %v, want %v", ep.posts, tt.posts)
+					break
+				}
+			}
+			if n := lines(t, s.dl.f.Name()); n != tt.failed {
+				t.Errorf("%d dead letter entries, want %d", n, tt.failed)
+			}
+		})
+	}
+}
+
+func TestDeadLetterFailure(t *testing.T) {
+	s, ep := newTestSink(t, 10)
+	path := s.dl.f.Name()
+	s.dl.f.Close()
+	done := make(map[string]bool)
+	if _, err := s.deliverAll(context.Background(), events("ok", "a"), done); err == nil {
+		t.Fatal("dead letter error expected")
+	}
+
+	//the retried block posts only the event that was not dead lettered
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.dl.f = f
+	failed, err := s.deliverAll(context.Background(), events("ok", "a"), done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 1 || ep.posts["ok"] != 1 || ep.posts["a"] != 4 {
+		t.Errorf("failed %d, posts %v", failed, ep.posts)
+	}
+	if n := lines(t, path); n != 1 {
+		t.Errorf("%d dead letter entries, want 1", n)
+	}
+}
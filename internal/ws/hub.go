@@ -0,0 +1,144 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package ws
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+//hub indexes client subscriptions by topic.
+//NOTE: subscriptions match txns whose note starts with the given base64 prefix,
+//all other topics match exactly.
+type hub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*client]struct{}
+	notes  map[*client]map[string]struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		topics: make(map[string]map[*client]struct{}),
+		notes:  make(map[*client]map[string]struct{}),
+	}
+}
+
+//checkTopic validates the ACC:, ASA:, APP:, NOTE: and GRP: grammar of TxWrap.Topics,
+//anything else is taken as a topic set by rules
+func checkTopic(topic string) error {
+	a := strings.SplitN(topic, ":", 2)
+	if topic == "" {
+		return fmt.Errorf("empty topic")
+	}
+	if len(a) < 2 {
+		return nil
+	}
+	switch a[0] {
+	case "ACC":
+		if _, err := types.DecodeAddress(a[1]); err != nil {
+			return fmt.Errorf("%s: %s", topic, err)
+		}
+	case "ASA", "APP":
+		if _, err := strconv.ParseUint(a[1], 10, 64); err != nil {
+			return fmt.Errorf("%s: invalid id", topic)
+		}
+	case "NOTE", "GRP":
+		if a[1] == "" {
+			return fmt.Errorf("%s: empty value", topic)
+		}
+	}
+	return nil
+}
+
+func (h *hub) subscribe(c *client, topics []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, t := range topics {
+		if strings.HasPrefix(t, "NOTE:") {
+			if h.notes[c] == nil {
+				h.notes[c] = make(map[string]struct{})
+			}
+			h.notes[c][t] = struct{}{}
+			continue
+		}
+		if h.topics[t] == nil {
+			h.topics[t] = make(map[*client]struct{})
+		}
+		h.topics[t][c] = struct{}{}
+	}
+}
+
+func (h *hub) unsubscribe(c *client, topics []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, t := range topics {
+		if strings.HasPrefix(t, "NOTE:") {
+			delete(h.notes[c], t)
+			if len(h.notes[c]) == 0 {
+				delete(h.notes, c)
+			}
+			continue
+		}
+		delete(h.topics[t], c)
+		if len(h.topics[t]) == 0 {
+			delete(h.topics, t)
+		}
+	}
+}
+
+//remove drops all subscriptions of a client
+func (h *hub) remove(c *client) {
+	c.mu.Lock()
+	topics := make([]string, 0, len(c.topics))
+	for t := range c.topics {
+		topics = append(topics, t)
+	}
+	c.mu.Unlock()
+	h.unsubscribe(c, topics)
+}
+
+//match returns every client subscribed to any of the txn topics, each client once
+func (h *hub) match(keys []string) map[*client]struct{} {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var out map[*client]struct{}
+	add := func(c *client) {
+		if out == nil {
+			out = make(map[*client]struct{})
+		}
+		out[c] = struct{}{}
+	}
+	for _, k := range keys {
+		for c := range h.topics[k] {
+			add(c)
+		}
+		if strings.HasPrefix(k, "NOTE:") {
+			for c, prefixes := range h.notes {
+				for p := range prefixes {
+					if strings.HasPrefix(k, p) {
+						add(c)
+						break
+					}
+				}
+			}
+		}
+	}
+	return out
+}
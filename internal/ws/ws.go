@@ -0,0 +1,341 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package ws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultListen    = ":8090"
+	defaultPath      = "/ws"
+	defaultBuffer    = 256
+	defaultMaxTopics = 100
+	defaultWriteWait = 10
+	pingInterval     = 30 * time.Second
+	pongWait         = 60 * time.Second
+	maxRequestSize   = 64 * 1024
+)
+
+type WsConfig struct {
+	//Listen is the address of the embedded HTTP server
+	Listen string `json:"listen"`
+	Path   string `json:"path"`
+	//Origins allowed to connect, "*" allows any, empty allows same origin only
+	Origins []string `json:"origins"`
+	//Buffer is the number of messages queued per connection,
+	//clients falling further behind are disconnected
+	Buffer    int `json:"buffer"`
+	MaxTopics int `json:"maxTopics"`
+	//WriteWait is the number of seconds a single write may take
+	WriteWait int `json:"writeWait"`
+}
+
+//request is a client subscription message
+type request struct {
+	Op     string   `json:"op"`
+	Topics []string `json:"topics"`
+}
+
+//reply acknowledges requests, txns are pushed as plain TxWrap JSON
+type reply struct {
+	Op     string   `json:"op,omitempty"`
+	Topics []string `json:"topics,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+	//slow is closed when the client falls behind
+	slow     chan struct{}
+	slowOnce sync.Once
+	//done is closed when the reader stops
+	done   chan struct{}
+	mu     sync.Mutex
+	topics map[string]struct{}
+}
+
+type wsSink struct {
+	sink.NoCheckpoint
+	name     string
+	cfg      *WsConfig
+	hub      *hub
+	srv      *http.Server
+	upgrader websocket.Upgrader
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	clients  map[*client]struct{}
+}
+
+func init() {
+	sink.Register("websocket", func() sink.Sink { return &wsSink{} })
+}
+
+func (s *wsSink) Init(ctx context.Context, name string, cfg json.RawMessage) error {
+	s.name = name
+	s.cfg = &WsConfig{}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, s.cfg); err != nil {
+			return fmt.Errorf("[WS] invalid config: %s", err)
+		}
+	}
+	if s.cfg.Listen == "" {
+		s.cfg.Listen = defaultListen
+	}
+	if s.cfg.Path == "" {
+		s.cfg.Path = defaultPath
+	}
+	if s.cfg.Buffer <= 0 {
+		s.cfg.Buffer = defaultBuffer
+	}
+	if s.cfg.MaxTopics <= 0 {
+		s.cfg.MaxTopics = defaultMaxTopics
+	}
+	if s.cfg.WriteWait <= 0 {
+		s.cfg.WriteWait = defaultWriteWait
+	}
+	s.hub = newHub()
+	s.clients = make(map[*client]struct{})
+	s.upgrader = websocket.Upgrader{CheckOrigin: s.checkOrigin}
+
+	ln, err := net.Listen("tcp", s.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("[WS] %s", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.cfg.Path, s.serve)
+	s.srv = &http.Server{Handler: mux}
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "[!ERR][WS][%s] %s\n", s.name, err)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "[INFO][WS][%s] Listening on %s%s\n", s.name, s.cfg.Listen, s.cfg.Path)
+	return nil
+}
+
+func (s *wsSink) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(s.cfg.Origins) == 0 {
+		if origin == "" {
+			return true
+		}
+		return origin == "http://"+r.Host || origin == "https://"+r.Host
+	}
+	for _, o := range s.cfg.Origins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *wsSink) serve(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &client{
+		conn:   conn,
+		send:   make(chan []byte, s.cfg.Buffer),
+		slow:   make(chan struct{}),
+		done:   make(chan struct{}),
+		topics: make(map[string]struct{}),
+	}
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+	s.wg.Add(1)
+	go s.writer(c)
+	s.reader(c)
+}
+
+//reader handles subscription requests until the connection fails
+func (s *wsSink) reader(c *client) {
+	defer func() {
+		s.hub.remove(c)
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+		close(c.done)
+	}()
+	c.conn.SetReadLimit(maxRequestSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		var req request
+		if err := c.conn.ReadJSON(&req); err != nil {
+			switch err.(type) {
+			case *json.SyntaxError, *json.UnmarshalTypeError:
+				s.reply(c, reply{Error: "invalid request"})
+				continue
+			}
+			return
+		}
+		s.reply(c, s.handle(c, &req))
+	}
+}
+
+func (s *wsSink) handle(c *client, req *request) reply {
+	for _, t := range req.Topics {
+		if err := checkTopic(t); err != nil {
+			return reply{Op: req.Op, Error: err.Error()}
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch req.Op {
+	case "subscribe":
+		added := make([]string, 0, len(req.Topics))
+		for _, t := range req.Topics {
+			if _, ok := c.topics[t]; !ok {
+				added = append(added, t)
+			}
+		}
+		if len(c.topics)+len(added) > s.cfg.MaxTopics {
+			return reply{Op: req.Op, Error: fmt.Sprintf("too many topics, limit is %d", s.cfg.MaxTopics)}
+		}
+		for _, t := range added {
+			c.topics[t] = struct{}{}
+		}
+		s.hub.subscribe(c, added)
+	case "unsubscribe":
+		for _, t := range req.Topics {
+			delete(c.topics, t)
+		}
+		s.hub.unsubscribe(c, req.Topics)
+	default:
+		return reply{Op: req.Op, Error: "op must be subscribe or unsubscribe"}
+	}
+	topics := make([]string, 0, len(c.topics))
+	for t := range c.topics {
+		topics = append(topics, t)
+	}
+	return reply{Op: req.Op, Topics: topics}
+}
+
+func (s *wsSink) reply(c *client, r reply) {
+	j, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	s.push(c, j)
+}
+
+//push queues a message without blocking, a full buffer marks the client as slow
+func (s *wsSink) push(c *client, msg []byte) {
+	select {
+	case c.send <- msg:
+	default:
+		c.slowOnce.Do(func() { close(c.slow) })
+	}
+}
+
+//writer sends queued messages and pings, slow clients get a policy violation close frame
+func (s *wsSink) writer(c *client) {
+	defer s.wg.Done()
+	defer c.conn.Close()
+	writeWait := time.Duration(s.cfg.WriteWait) * time.Second
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-c.slow:
+			fmt.Fprintf(os.Stderr, "[WARN][WS][%s] Disconnecting slow consumer %s\n", s.name, c.conn.RemoteAddr())
+			c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "slow consumer"), time.Now().Add(writeWait))
+			return
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+//HandleBlock pushes every txn to clients subscribed to one of its topics.
+//Txns routed to a topic by rules match subscriptions to that topic only.
+func (s *wsSink) HandleBlock(ctx context.Context, b *sink.Block) error {
+	for _, t := range b.Txns {
+		keys := []string{t.Topic}
+		if t.Topic == "" {
+			keys = t.Topics()
+		}
+		clients := s.hub.match(keys)
+		if len(clients) == 0 {
+			continue
+		}
+		j, err := t.Encode(t.TxWrap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][WS] %s\n", err)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, j); err != nil {
+			fmt.Fprintf(os.Stderr, "[!ERR][WS] %s\n", err)
+			continue
+		}
+		msg := buf.Bytes()
+		for c := range clients {
+			s.push(c, msg)
+		}
+	}
+	return nil
+}
+
+func (s *wsSink) HandleStatus(ctx context.Context, status *sink.Status) error {
+	return nil
+}
+
+func (s *wsSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+//Close stops accepting connections and closes the open ones
+func (s *wsSink) Close(ctx context.Context) error {
+	if s.srv == nil {
+		return nil
+	}
+	err := s.srv.Shutdown(ctx)
+	s.mu.Lock()
+	for c := range s.clients {
+		c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down"), time.Now().Add(time.Second))
+		c.conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,189 @@
+// Copyright (C) 2022 AlgoNode Org.
+//
+// algostreamer is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// algostreamer is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with algostreamer.  If not, see <https://www.gnu.org/licenses/>.
+
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/algonode/algostreamer/internal/algod"
+	"github.com/algonode/algostreamer/internal/sink"
+	"github.com/algorand/go-algorand-sdk/types"
+	"github.com/gorilla/websocket"
+)
+
+var alice = types.Address{1}
+
+//testSink serves the sink with httptest instead of the configured listener
+func testSink(t *testing.T, buffer int) (*wsSink, string) {
+	s := &wsSink{
+		name:    "test",
+		cfg:     &WsConfig{Buffer: buffer, MaxTopics: defaultMaxTopics, WriteWait: defaultWriteWait},
+		hub:     newHub(),
+		clients: make(map[*client]struct{}),
+	}
+	s.upgrader = websocket.Upgrader{CheckOrigin: s.checkOrigin}
+	srv := httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(srv.Close)
+	return s, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+//dial connects a client and waits for the reply to its request so the hub is updated
+func dial(t *testing.T, url string, op string, topics ...string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	send(t, conn, op, topics...)
+	return conn
+}
+
+func send(t *testing.T, conn *websocket.Conn, op string, topics ...string) {
+	if err := conn.WriteJSON(&request{Op: op, Topics: topics}); err != nil {
+		t.Fatal(err)
+	}
+	var r reply
+	if err := conn.ReadJSON(&r); err != nil || r.Error != "" {
+		t.Fatalf("%s %v: %v %s", op, topics, err, r.Error)
+	}
+}
+
+func testBlock(notes ...string) *sink.Block {
+	b := &sink.Block{BlockWrap: &algod.BlockWrap{Block: &types.Block{BlockHeader: types.BlockHeader{Round: 10}}}}
+	for i, n := range notes {
+		tx := types.Transaction{Type: types.PaymentTx}
+		tx.Sender, tx.Note = alice, []byte(n)
+		b.Txns = append(b.Txns, &sink.Tx{TxWrap: &algod.TxWrap{
+			TxId:  "TX" + n[:3],
+			Txn:   &types.SignedTxnInBlock{SignedTxnWithAD: types.SignedTxnWithAD{SignedTxn: types.SignedTxn{Txn: tx}}},
+			Round: 10,
+			Intra: i,
+		}})
+	}
+	return b
+}
+
+//mark queues an empty request, its reply follows every txn pushed before
+func mark(t *testing.T, conn *websocket.Conn) {
+	if err := conn.WriteJSON(&request{Op: "subscribe"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//next returns the txid of the next pushed txn or an empty string for a reply
+func next(t *testing.T, conn *websocket.Conn) string {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg struct {
+		Op   string `json:"op"`
+		TxId string `json:"txid"`
+	}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg.TxId
+}
+
+func TestNotePrefix(t *testing.T) {
+	s, url := testSink(t, defaultBuffer)
+	//"hello" and "foo" in base64, shorter than the 32 chars of the txn topic
+	hello := dial(t, url, "subscribe", "NOTE:aGVsbG8")
+	foo := dial(t, url, "subscribe", "NOTE:Zm9v")
+	both := dial(t, url, "subscribe", "NOTE:aGVsbG8", "NOTE:Zm9v", "ACC:"+alice.String())
+
+	ctx := context.Background()
+	if err := s.HandleBlock(ctx, testBlock("hello world", "foobar", "bar")); err != nil {
+		t.Fatal(err)
+	}
+	if id := next(t, hello); id != "TXhel" {
+		t.Errorf("hello got %q", id)
+	}
+	if id := next(t, foo); id != "TXfoo" {
+		t.Errorf("foo got %q", id)
+	}
+	//one message per txn even when several subscriptions match
+	mark(t, both)
+	for _, want := range []string{"TXhel", "TXfoo", "TXbar", ""} {
+		if id := next(t, both); id != want {
+			t.Errorf("both got %q, want %q", id, want)
+		}
+	}
+	mark(t, hello)
+	if id := next(t, hello); id != "" {
+		t.Errorf("hello got %q after its txn", id)
+	}
+
+	send(t, hello, "unsubscribe", "NOTE:aGVsbG8")
+	if err := s.HandleBlock(ctx, testBlock("hello again")); err != nil {
+		t.Fatal(err)
+	}
+	mark(t, hello)
+	if id := next(t, hello); id != "" {
+		t.Errorf("unsubscribed client got %q", id)
+	}
+}
+
+func TestSlowConsumer(t *testing.T) {
+	s, url := testSink(t, 1)
+	conn := dial(t, url, "subscribe", "ACC:"+alice.String())
+	fast := dial(t, url, "subscribe", "NOTE:Zm9v")
+
+	//a client that stops reading fills the socket buffers and then its queue
+	notes := make([]string, 10000)
+	for i := range notes {
+		notes[i] = "bar" + strings.Repeat("x", 1000)
+	}
+	if err := s.HandleBlock(context.Background(), testBlock(notes...)); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+			t.Fatalf("slow consumer got %v, want a policy violation close", err)
+		}
+		break
+	}
+
+	//other clients keep their subscriptions
+	if err := s.HandleBlock(context.Background(), testBlock("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if id := next(t, fast); id != "TXfoo" {
+		t.Errorf("fast got %q", id)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		n := len(s.clients)
+		s.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d clients registered, want 1", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}